@@ -1,37 +1,162 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/database"
 	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/health"
+	"github.com/4otis/library_api_2025/internal/logging"
+	"github.com/4otis/library_api_2025/internal/metrics"
 	"github.com/4otis/library_api_2025/internal/migrations"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/server"
+	"github.com/4otis/library_api_2025/internal/tracing"
 
 	"github.com/labstack/echo/v4"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // @title Library API
 // @version 1.0
 // @description test msg
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by an access token from /auth/login
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description "ApiKey " followed by a key from /api-keys
 func main() {
-	e := echo.New()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error. Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	logger := logging.New(cfg.Log, os.Stdout)
+	slog.SetDefault(logger)
+	slog.Info("Config loaded", "config", cfg)
 
-	dsn := "host=localhost user=postgres password=password dbname=library port=5432 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Error. Failed to set up tracing", err)
+	}
+
+	db, err := database.Open(cfg.DB, &gorm.Config{
+		Logger:         logging.NewGormLogger(logger, cfg.DB.LogLevel, cfg.DB.SlowThreshold, cfg.DB.LogParams),
+		TranslateError: true,
 	})
 	if err != nil {
-		log.Fatal("Error. Failed to connect to db.")
+		fatal("Error. Failed to connect to db", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("Error. Failed to connect to db", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+
+	m := metrics.New()
+	m.RegisterDB(sqlDB, cfg.DB.Name)
+	err = db.Use(m.GormPlugin())
+	if err != nil {
+		fatal("Error. Failed to set up metrics", err)
+	}
+	err = db.Use(tracing.NewGormPlugin())
+	if err != nil {
+		fatal("Error. Failed to set up tracing", err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		err = runMigrate(db, args[1:])
+		if err != nil {
+			fatal("Error. Failed to migrate db", err)
+		}
+		return
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		fatal("Error. Failed to migrate db", err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		fatal("Error. Failed to migrate db", err)
 	}
 
-	err = migrations.RunInitMigrations(db)
+	if len(args) > 0 && args[0] == "user" {
+		err = runUser(db, args[1:], os.Stdin)
+		if err != nil {
+			fatal("Error. Failed to manage users", err)
+		}
+		return
+	}
+
+	tokens, err := auth.NewTokens(cfg.Auth)
 	if err != nil {
-		log.Fatal("Error. Failed to migrated db.")
+		fatal("Error. Failed to set up authentication", err)
 	}
+	if cfg.Auth.SigningKeys == "" {
+		slog.Warn("No signing keys configured, tokens won't survive a restart")
+	}
+
+	e := echo.New()
+	e.Use(
+		logging.RequestIDMiddleware(),
+		tracing.Middleware(),
+		logging.AccessLogMiddleware(logger, cfg.Log.SampleRate),
+		m.Middleware(),
+	)
+
+	srv := server.New(cfg, e)
+	srv.Go("catalog metrics", m.CatalogWorker(db, cfg.Metrics.CatalogInterval))
+	srv.Go("hold expiry", repository.NewHoldRepository(db, cfg.Circulation).ExpiryWorker(cfg.Circulation.HoldExpiryInterval))
+	srv.OnStop("database", func(context.Context) error {
+		return sqlDB.Close()
+	})
+	srv.OnStop("tracing", shutdownTracing)
+
+	ready := health.NewChecker(cfg.Health.CheckTimeout)
+	ready.Add("shutdown", health.NotDraining(srv.Draining))
+	ready.Add("database", health.Ping(sqlDB))
+	ready.Add("migrations", health.SchemaVersion(migrator.Version, migrator.Latest()))
+	ready.Add("pool", health.PoolUsage(sqlDB, cfg.Health.MaxPoolUsage))
+
+	handlers.SetupHealthRoutes(e, handlers.NewHealthHandler(ready))
+	handlers.SetupMetricsRoutes(e, m.Handler())
+	handlers.SetupRoutes(e, db, tokens, cfg.Auth.RefreshTTL, cfg.Circulation, cfg.API)
 
-	handlers.SetupRoutes(e, db)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		// A second signal kills the process instead of waiting.
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = srv.Run(ctx)
+	if err != nil {
+		fatal("Error. Server stopped", err)
+	}
+	slog.Info("Server stopped")
+}
 
-	e.Logger.Fatal(e.Start(":1323"))
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"gorm.io/gorm"
+)
+
+const userUsage = "usage: user create <username> [role...] (password is read from stdin)"
+
+// runUser handles the "user" command: "create" adds a user with the
+// password given on the first line of stdin, so it doesn't end up in
+// the shell history. The user gets the given roles, or reader without
+// any, so the first admin can be created before the API is usable.
+func runUser(db *gorm.DB, args []string, stdin io.Reader) error {
+	if len(args) < 2 || args[0] != "create" {
+		return errors.New(userUsage)
+	}
+
+	username := args[1]
+	if len(username) > 64 {
+		return errors.New("username must be at most 64 characters")
+	}
+
+	roles := args[2:]
+	if len(roles) == 0 {
+		roles = []string{string(auth.RoleReader)}
+	}
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			return fmt.Errorf("unknown role %q, expected one of %v", role, auth.Roles())
+		}
+	}
+
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < 8 || len(password) > auth.MaxPasswordLength {
+		return fmt.Errorf("password must be between 8 and %d bytes", auth.MaxPasswordLength)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{Username: username, PasswordHash: hash}
+	for _, role := range slices.Compact(slices.Sorted(slices.Values(roles))) {
+		user.Roles = append(user.Roles, models.UserRole{Role: role})
+	}
+	err = repository.NewUserRepository(db).Create(context.Background(), &user)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("user %q already exists", username)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %d %s %s\n", user.ID, user.Username, strings.Join(roles, ","))
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package docs holds the generated swagger spec, "make docs" replaces
+// this placeholder with it.
+package docs
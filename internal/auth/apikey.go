@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"slices"
+	"strings"
+)
+
+// APIKeyChecker returns the scopes of an API key that exists, matches
+// the hash and hasn't expired or been revoked, and records its use.
+type APIKeyChecker interface {
+	Use(ctx context.Context, id, hash string) (scopes []string, ok bool, err error)
+}
+
+// scopes are the permissions API keys can be granted. Managing users
+// and keys is left to people.
+var scopes = []Permission{BooksRead, BooksWrite, BooksDelete, AuthorsRead, AuthorsWrite, AuthorsDelete}
+
+func Scopes() []Permission {
+	return slices.Clone(scopes)
+}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(scopes, Permission(scope))
+}
+
+// NewAPIKey returns a new key, its id followed by a dot and a random
+// secret, and the hash of the secret to store. The id is public and
+// identifies the key in listings and logs.
+func NewAPIKey() (id, key, hash string) {
+	id = "lk_" + strings.ToLower(rand.Text()[:16])
+	key, hash = newCredential(id)
+	return id, key, hash
+}
+
+// ParseAPIKey returns the id of a key and the hash of its secret.
+func ParseAPIKey(key string) (id, hash string, err error) {
+	return parseCredential(key)
+}
+
+// scopePermissions returns the valid scopes as permissions.
+func scopePermissions(granted []string) []Permission {
+	var perms []Permission
+	for _, scope := range granted {
+		if ValidScope(scope) {
+			perms = append(perms, Permission(scope))
+		}
+	}
+	return perms
+}
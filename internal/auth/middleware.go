@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// SessionChecker tells whether a session hasn't expired or been
+// revoked.
+type SessionChecker interface {
+	Active(ctx context.Context, id string) (bool, error)
+}
+
+// RoleLoader returns the roles currently assigned to a user, so role
+// changes apply to tokens issued before them.
+type RoleLoader interface {
+	Roles(ctx context.Context, userID uint) ([]string, error)
+}
+
+// Principal is the authenticated caller of a request, either a user's
+// session or an API key.
+type Principal struct {
+	UserID      uint
+	SessionID   string
+	APIKeyID    string
+	Permissions []Permission
+}
+
+func (p Principal) Can(perm Permission) bool {
+	return slices.Contains(p.Permissions, perm)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored by Middleware.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware requires either an "Authorization: Bearer" access token
+// whose session is still active or an "Authorization: ApiKey" key that
+// hasn't expired or been revoked. It stores the principal in the
+// request context, with the permissions of the user's roles or the
+// scopes of the key. Other requests fail with 401.
+func Middleware(tokens *Tokens, sessions SessionChecker, users RoleLoader, keys APIKeyChecker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var p Principal
+			var err error
+			scheme, credential, _ := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+			switch {
+			case credential == "":
+				err = Unauthorized(c, "Bearer", "", "An access token is required.")
+			case strings.EqualFold(scheme, "Bearer"):
+				p, err = authenticateToken(c, tokens, sessions, users, credential)
+			case strings.EqualFold(scheme, "ApiKey"):
+				p, err = authenticateKey(c, keys, credential)
+			default:
+				err = Unauthorized(c, "Bearer", "", "An access token is required.")
+			}
+			if err != nil {
+				return err
+			}
+
+			c.SetRequest(c.Request().WithContext(WithPrincipal(c.Request().Context(), p)))
+			return next(c)
+		}
+	}
+}
+
+func authenticateToken(c echo.Context, tokens *Tokens, sessions SessionChecker, users RoleLoader, token string) (Principal, error) {
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		return Principal{}, Unauthorized(c, "Bearer", "invalid_token", "The access token is invalid or expired.")
+	}
+
+	ctx := c.Request().Context()
+	active, err := sessions.Active(ctx, claims.SessionID)
+	if err != nil {
+		return Principal{}, problem.Internal(err)
+	}
+	if !active {
+		return Principal{}, Unauthorized(c, "Bearer", "invalid_token", "The session has ended.")
+	}
+
+	userID, _ := claims.UserID()
+	roles, err := users.Roles(ctx, userID)
+	if err != nil {
+		return Principal{}, problem.Internal(err)
+	}
+
+	return Principal{UserID: userID, SessionID: claims.SessionID, Permissions: PermissionsOf(roles)}, nil
+}
+
+func authenticateKey(c echo.Context, keys APIKeyChecker, key string) (Principal, error) {
+	id, hash, err := ParseAPIKey(key)
+	if err != nil {
+		return Principal{}, Unauthorized(c, "ApiKey", "invalid_key", "The API key is invalid.")
+	}
+
+	scopes, ok, err := keys.Use(c.Request().Context(), id, hash)
+	if err != nil {
+		return Principal{}, problem.Internal(err)
+	}
+	if !ok {
+		return Principal{}, Unauthorized(c, "ApiKey", "invalid_key", "The API key is invalid, expired or revoked.")
+	}
+
+	return Principal{APIKeyID: id, Permissions: scopePermissions(scopes)}, nil
+}
+
+// Unauthorized sets a WWW-Authenticate challenge for scheme, with the
+// error code if it's not empty (RFC 6750), and returns a 401 problem.
+func Unauthorized(c echo.Context, scheme, code, detail string) *problem.Problem {
+	challenge := scheme
+	if code != "" {
+		challenge += ` error="` + code + `"`
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, detail)
+}
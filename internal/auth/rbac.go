@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+)
+
+type Role string
+
+const (
+	RoleReader     Role = "reader"
+	RoleLibrarian  Role = "librarian"
+	RoleCataloguer Role = "cataloguer"
+	RoleAdmin      Role = "admin"
+)
+
+// Permission allows a kind of operation, e.g. "books:write" covers
+// creating and editing books.
+type Permission string
+
+const (
+	BooksRead     Permission = "books:read"
+	BooksWrite    Permission = "books:write"
+	BooksDelete   Permission = "books:delete"
+	AuthorsRead   Permission = "authors:read"
+	AuthorsWrite  Permission = "authors:write"
+	AuthorsDelete Permission = "authors:delete"
+	MembersRead   Permission = "members:read"
+	MembersWrite  Permission = "members:write"
+	MembersDelete Permission = "members:delete"
+	CopiesWrite   Permission = "copies:write"
+	CopiesDelete  Permission = "copies:delete"
+	LoansRead     Permission = "loans:read"
+	LoansWrite    Permission = "loans:write"
+	HoldsRead     Permission = "holds:read"
+	HoldsWrite    Permission = "holds:write"
+	UsersManage   Permission = "users:manage"
+	APIKeysManage Permission = "api_keys:manage"
+)
+
+var (
+	readPermissions        = []Permission{BooksRead, AuthorsRead}
+	writePermissions       = []Permission{BooksWrite, AuthorsWrite}
+	circulationPermissions = []Permission{
+		MembersRead, MembersWrite, CopiesWrite, LoansRead, LoansWrite, HoldsRead, HoldsWrite,
+	}
+)
+
+// rolePermissions grants each role its permissions. Librarians work
+// the circulation desk, cataloguers the catalog. Admins can do
+// everything, including deleting and managing users and API keys.
+var rolePermissions = map[Role][]Permission{
+	RoleReader:     readPermissions,
+	RoleLibrarian:  slices.Concat(readPermissions, circulationPermissions),
+	RoleCataloguer: slices.Concat(readPermissions, writePermissions, []Permission{CopiesWrite}),
+	RoleAdmin: slices.Concat(readPermissions, writePermissions, circulationPermissions,
+		[]Permission{BooksDelete, AuthorsDelete, MembersDelete, CopiesDelete, UsersManage, APIKeysManage}),
+}
+
+// Roles returns every role, least privileged first.
+func Roles() []Role {
+	return []Role{RoleReader, RoleLibrarian, RoleCataloguer, RoleAdmin}
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// PermissionsOf returns the permissions granted by any of the roles.
+// Unknown roles grant nothing.
+func PermissionsOf(roles []string) []Permission {
+	var perms []Permission
+	for _, role := range roles {
+		for _, p := range rolePermissions[Role(role)] {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	return perms
+}
+
+// Require lets a request through if its principal has every one of
+// perms, otherwise it fails with 403. It must run after Middleware.
+func Require(perms ...Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, _ := PrincipalFrom(c.Request().Context())
+
+			var missing []string
+			for _, perm := range perms {
+				if !p.Can(perm) {
+					missing = append(missing, string(perm))
+				}
+			}
+			if len(missing) > 0 {
+				return problem.New(http.StatusForbidden, problem.CodeForbidden,
+					fmt.Sprintf("Missing permission %s.", strings.Join(missing, ", ")))
+			}
+
+			return next(c)
+		}
+	}
+}
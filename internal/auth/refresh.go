@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// NewSessionID returns a random session id.
+func NewSessionID() string {
+	return rand.Text()
+}
+
+// NewRefreshToken returns a refresh token for the session, the session
+// id and a random secret joined by a dot, and the hash to store.
+func NewRefreshToken(sessionID string) (token, hash string) {
+	return newCredential(sessionID)
+}
+
+// ParseRefreshToken returns the session id of a refresh token and the
+// hash of its secret.
+func ParseRefreshToken(token string) (sessionID, hash string, err error) {
+	return parseCredential(token)
+}
+
+// newCredential joins id and a random secret by a dot and returns it
+// with the hash of the secret, which is all that gets stored.
+func newCredential(id string) (credential, hash string) {
+	secret := rand.Text()
+	return id + "." + secret, hashSecret(secret)
+}
+
+func parseCredential(credential string) (id, hash string, err error) {
+	id, secret, ok := strings.Cut(credential, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidToken
+	}
+	return id, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
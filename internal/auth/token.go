@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// EphemeralKeyID names the key generated when none is configured.
+const EphemeralKeyID = "ephemeral"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims of an access token. The subject is the user
+// id, SessionID ties the token to the session that logout revokes.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// UserID returns the user id held in the subject.
+func (c Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 0)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// Tokens issues and verifies HS256 access tokens. The kid header names
+// the key a token was signed with, so tokens signed with any of the
+// configured keys are accepted.
+type Tokens struct {
+	keys   map[string][]byte
+	kid    string
+	issuer string
+	ttl    time.Duration
+}
+
+func NewTokens(cfg config.Auth) (*Tokens, error) {
+	keys, err := cfg.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	kid := cfg.SigningKeyID
+	if len(keys) == 0 {
+		kid = EphemeralKeyID
+		keys[kid] = []byte(rand.Text() + rand.Text())
+	}
+
+	return &Tokens{keys: keys, kid: kid, issuer: cfg.Issuer, ttl: cfg.AccessTTL}, nil
+}
+
+// Issue signs an access token for the user's session.
+func (t Tokens) Issue(userID uint, sessionID string) (token string, expiresAt time.Time, err error) {
+	now := time.Now()
+	expiresAt = now.Add(t.ttl)
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionID: sessionID,
+	})
+	jwtToken.Header["kid"] = t.kid
+
+	token, err = jwtToken.SignedString(t.keys[t.kid])
+	return token, expiresAt, err
+}
+
+// Verify checks the signature, issuer and expiry of an access token.
+func (t Tokens) Verify(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys[kid]
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(t.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
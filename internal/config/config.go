@@ -0,0 +1,337 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Secret is a string that is never printed, e.g. a password.
+type Secret string
+
+func (Secret) String() string {
+	return "***"
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalText keeps the secret out of JSON logs of the whole config.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	API         API         `yaml:"api"`
+	Shutdown    Shutdown    `yaml:"shutdown"`
+	Health      Health      `yaml:"health"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Log         Log         `yaml:"log"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Auth        Auth        `yaml:"auth"`
+	Circulation Circulation `yaml:"circulation"`
+	DB          DB          `yaml:"db"`
+}
+
+type HTTP struct {
+	Addr              string        `yaml:"addr"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+}
+
+// API limits what a single request may ask for. MaxIncludeDepth is
+// the longest association path allowed in ?include, e.g. 2 for
+// authors.books.
+type API struct {
+	MaxIncludeDepth int `yaml:"max_include_depth"`
+}
+
+// Shutdown controls how the server stops. During DrainTimeout it keeps
+// serving but reports itself not ready, Timeout bounds the wait for
+// in-flight requests and background workers after that.
+type Shutdown struct {
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// Health controls the readiness checks. The pool check fails once more
+// than MaxPoolUsage of the open connections are in use.
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout"`
+	MaxPoolUsage float64       `yaml:"max_pool_usage"`
+}
+
+// Metrics controls the Prometheus metrics. The catalog gauges are
+// recounted every CatalogInterval.
+type Metrics struct {
+	CatalogInterval time.Duration `yaml:"catalog_interval"`
+}
+
+// Log controls the application log. SampleRate is the share of
+// successful requests that get an access log line, failed requests
+// are always logged.
+type Log struct {
+	Level      string  `yaml:"level"`
+	Format     string  `yaml:"format"`
+	SampleRate float64 `yaml:"sample_rate"`
+}
+
+// Tracing controls the OpenTelemetry traces. Exporter is "none",
+// "stdout" or "otlp", the latter sends spans over OTLP/HTTP to
+// Endpoint. SampleRatio applies to traces started here, incoming
+// sampled traces are always recorded.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Auth controls the access and refresh tokens. SigningKeys lists
+// comma-separated "kid:secret" pairs. Tokens are signed with the key
+// named by SigningKeyID and accepted if signed by any listed key, so a
+// key is rotated by adding a new one, making it active and removing the
+// old one once its tokens have expired. Without keys a random key is
+// generated at startup and tokens don't survive a restart.
+type Auth struct {
+	SigningKeys  Secret        `yaml:"signing_keys"`
+	SigningKeyID string        `yaml:"signing_key_id"`
+	Issuer       string        `yaml:"issuer"`
+	AccessTTL    time.Duration `yaml:"access_ttl"`
+	RefreshTTL   time.Duration `yaml:"refresh_ttl"`
+}
+
+// MinKeySize is the minimum length of a signing key secret in bytes.
+const MinKeySize = 32
+
+// Keys parses SigningKeys into secrets by key id.
+func (a Auth) Keys() (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	if a.SigningKeys == "" {
+		return keys, nil
+	}
+
+	for _, pair := range strings.Split(string(a.SigningKeys), ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		switch {
+		case !ok || kid == "":
+			return nil, errors.New(`expected comma-separated "kid:secret" pairs`)
+		case len(secret) < MinKeySize:
+			return nil, fmt.Errorf("key %q must be at least %d bytes", kid, MinKeySize)
+		case keys[kid] != nil:
+			return nil, fmt.Errorf("duplicate key %q", kid)
+		}
+		keys[kid] = []byte(secret)
+	}
+	return keys, nil
+}
+
+// Circulation is the lending policy. A loan is due LoanPeriod after
+// checkout and a member may have at most LoanLimit copies out at once.
+// A loan can be renewed MaxRenewals times for another LoanPeriod, until
+// it's overdue by more than RenewalGrace. A copy held for a member
+// waits PickupWindow for them, missed pickups are looked for every
+// HoldExpiryInterval.
+type Circulation struct {
+	LoanPeriod         time.Duration `yaml:"loan_period"`
+	LoanLimit          int           `yaml:"loan_limit"`
+	MaxRenewals        int           `yaml:"max_renewals"`
+	RenewalGrace       time.Duration `yaml:"renewal_grace"`
+	PickupWindow       time.Duration `yaml:"pickup_window"`
+	HoldExpiryInterval time.Duration `yaml:"hold_expiry_interval"`
+}
+
+// DB selects the storage. Driver is "postgres", which uses the
+// connection settings, or "sqlite", which keeps everything in the file
+// at Path. The pool settings apply to both.
+type DB struct {
+	Driver          string        `yaml:"driver"`
+	Path            string        `yaml:"path"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        Secret        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	LogLevel        string        `yaml:"log_level"`
+	SlowThreshold   time.Duration `yaml:"slow_threshold"`
+	LogParams       bool          `yaml:"log_params"`
+}
+
+var (
+	drivers    = []string{"postgres", "sqlite"}
+	sslModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	sqlLevels  = []string{"silent", "error", "warn", "info"}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "text"}
+	exporters  = []string{"none", "stdout", "otlp"}
+)
+
+// Default returns the settings used when nothing overrides them, they
+// match the docker-compose setup.
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:              ":1323",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		},
+		API: API{
+			MaxIncludeDepth: 2,
+		},
+		Shutdown: Shutdown{
+			DrainTimeout: 5 * time.Second,
+			Timeout:      30 * time.Second,
+		},
+		Health: Health{
+			CheckTimeout: 2 * time.Second,
+			MaxPoolUsage: 0.9,
+		},
+		Metrics: Metrics{
+			CatalogInterval: 30 * time.Second,
+		},
+		Log: Log{
+			Level:      "info",
+			Format:     "json",
+			SampleRate: 1,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "library_api",
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			Issuer:     "library_api",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Circulation: Circulation{
+			LoanPeriod:         14 * 24 * time.Hour,
+			LoanLimit:          5,
+			MaxRenewals:        2,
+			RenewalGrace:       3 * 24 * time.Hour,
+			PickupWindow:       3 * 24 * time.Hour,
+			HoldExpiryInterval: time.Minute,
+		},
+		DB: DB{
+			Driver:          "postgres",
+			Path:            "library.db",
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Password:        "password",
+			Name:            "library",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			LogLevel:        "warn",
+			SlowThreshold:   200 * time.Millisecond,
+		},
+	}
+}
+
+// DSN returns the Postgres connection URL. It contains the password,
+// so it must only be handed to the driver.
+func (db DB) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.User, string(db.Password)),
+		Host:     net.JoinHostPort(db.Host, strconv.Itoa(db.Port)),
+		Path:     db.Name,
+		RawQuery: url.Values{"sslmode": {db.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Validate reports every invalid setting at once, each error names
+// the setting and the ways to set it.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", describe(key), fmt.Sprintf(format, args...)))
+		}
+	}
+
+	_, port, err := net.SplitHostPort(c.HTTP.Addr)
+	check(err == nil && port != "", "http.addr", "must be host:port or :port, got %q", c.HTTP.Addr)
+
+	check(c.HTTP.ReadTimeout >= 0, "http.read_timeout", "must not be negative, got %s", c.HTTP.ReadTimeout)
+	check(c.HTTP.ReadHeaderTimeout >= 0, "http.read_header_timeout", "must not be negative, got %s", c.HTTP.ReadHeaderTimeout)
+	check(c.HTTP.WriteTimeout >= 0, "http.write_timeout", "must not be negative, got %s", c.HTTP.WriteTimeout)
+	check(c.HTTP.IdleTimeout >= 0, "http.idle_timeout", "must not be negative, got %s", c.HTTP.IdleTimeout)
+	check(c.API.MaxIncludeDepth > 0, "api.max_include_depth", "must be positive, got %d", c.API.MaxIncludeDepth)
+	check(c.Shutdown.DrainTimeout >= 0, "shutdown.drain_timeout", "must not be negative, got %s", c.Shutdown.DrainTimeout)
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout", "must be positive, got %s", c.Shutdown.Timeout)
+	check(c.Health.CheckTimeout > 0, "health.check_timeout", "must be positive, got %s", c.Health.CheckTimeout)
+	check(c.Health.MaxPoolUsage > 0 && c.Health.MaxPoolUsage <= 1, "health.max_pool_usage",
+		"must be in (0, 1], got %g", c.Health.MaxPoolUsage)
+	check(c.Metrics.CatalogInterval > 0, "metrics.catalog_interval", "must be positive, got %s", c.Metrics.CatalogInterval)
+	check(slices.Contains(logLevels, c.Log.Level), "log.level", "must be one of %v, got %q", logLevels, c.Log.Level)
+	check(slices.Contains(logFormats, c.Log.Format), "log.format", "must be one of %v, got %q", logFormats, c.Log.Format)
+	check(c.Log.SampleRate >= 0 && c.Log.SampleRate <= 1, "log.sample_rate", "must be in [0, 1], got %g", c.Log.SampleRate)
+	check(slices.Contains(exporters, c.Tracing.Exporter), "tracing.exporter", "must be one of %v, got %q", exporters, c.Tracing.Exporter)
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint", "must not be empty with the otlp exporter")
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "must not be empty")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio",
+		"must be in [0, 1], got %g", c.Tracing.SampleRatio)
+
+	keys, err := c.Auth.Keys()
+	check(err == nil, "auth.signing_keys", "%v", err)
+	check(len(keys) == 0 || keys[c.Auth.SigningKeyID] != nil, "auth.signing_key_id",
+		"must name one of the signing keys, got %q", c.Auth.SigningKeyID)
+	check(c.Auth.Issuer != "", "auth.issuer", "must not be empty")
+	check(c.Auth.AccessTTL > 0, "auth.access_ttl", "must be positive, got %s", c.Auth.AccessTTL)
+	check(c.Auth.RefreshTTL >= c.Auth.AccessTTL, "auth.refresh_ttl",
+		"must not be shorter than auth.access_ttl, got %s", c.Auth.RefreshTTL)
+
+	check(c.Circulation.LoanPeriod > 0, "circulation.loan_period", "must be positive, got %s", c.Circulation.LoanPeriod)
+	check(c.Circulation.LoanLimit > 0, "circulation.loan_limit", "must be positive, got %d", c.Circulation.LoanLimit)
+	check(c.Circulation.MaxRenewals >= 0, "circulation.max_renewals", "must not be negative, got %d", c.Circulation.MaxRenewals)
+	check(c.Circulation.RenewalGrace >= 0, "circulation.renewal_grace", "must not be negative, got %s", c.Circulation.RenewalGrace)
+	check(c.Circulation.PickupWindow > 0, "circulation.pickup_window", "must be positive, got %s", c.Circulation.PickupWindow)
+	check(c.Circulation.HoldExpiryInterval > 0, "circulation.hold_expiry_interval",
+		"must be positive, got %s", c.Circulation.HoldExpiryInterval)
+
+	check(slices.Contains(drivers, c.DB.Driver), "db.driver", "must be one of %v, got %q", drivers, c.DB.Driver)
+	if c.DB.Driver == "sqlite" {
+		check(c.DB.Path != "", "db.path", "must not be empty with the sqlite driver")
+	} else {
+		check(c.DB.Host != "", "db.host", "must not be empty")
+		check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port", "must be between 1 and 65535, got %d", c.DB.Port)
+		check(c.DB.User != "", "db.user", "must not be empty")
+		check(c.DB.Name != "", "db.name", "must not be empty")
+		check(slices.Contains(sslModes, c.DB.SSLMode), "db.sslmode", "must be one of %v, got %q", sslModes, c.DB.SSLMode)
+	}
+	check(c.DB.MaxOpenConns > 0, "db.max_open_conns", "must be positive, got %d", c.DB.MaxOpenConns)
+	check(c.DB.MaxIdleConns >= 0 && c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db.max_idle_conns",
+		"must be between 0 and db.max_open_conns, got %d", c.DB.MaxIdleConns)
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime", "must not be negative, got %s", c.DB.ConnMaxLifetime)
+	check(slices.Contains(sqlLevels, c.DB.LogLevel), "db.log_level", "must be one of %v, got %q", sqlLevels, c.DB.LogLevel)
+	check(c.DB.SlowThreshold >= 0, "db.slow_threshold", "must not be negative, got %s", c.DB.SlowThreshold)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "LIBRARY_"
+
+// setting binds a config key to an environment variable and a flag,
+// e.g. db.max_open_conns to LIBRARY_DB_MAX_OPEN_CONNS and
+// -db-max-open-conns.
+type setting struct {
+	key    string
+	usage  string
+	set    func(c *Config, value string) error
+	isBool bool
+}
+
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+var settings = []setting{
+	stringSetting("http.addr", "HTTP listen address", func(c *Config) *string { return &c.HTTP.Addr }),
+	durationSetting("http.read_timeout", "maximum time to read a request",
+		func(c *Config) *time.Duration { return &c.HTTP.ReadTimeout }),
+	durationSetting("http.read_header_timeout", "maximum time to read request headers",
+		func(c *Config) *time.Duration { return &c.HTTP.ReadHeaderTimeout }),
+	durationSetting("http.write_timeout", "maximum time to write a response",
+		func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout }),
+	durationSetting("http.idle_timeout", "maximum keep-alive idle time",
+		func(c *Config) *time.Duration { return &c.HTTP.IdleTimeout }),
+	intSetting("api.max_include_depth", "longest association path allowed in ?include",
+		func(c *Config) *int { return &c.API.MaxIncludeDepth }),
+	durationSetting("shutdown.drain_timeout", "time to keep serving while reporting not ready on shutdown",
+		func(c *Config) *time.Duration { return &c.Shutdown.DrainTimeout }),
+	durationSetting("shutdown.timeout", "maximum time to finish requests and stop workers on shutdown",
+		func(c *Config) *time.Duration { return &c.Shutdown.Timeout }),
+	durationSetting("health.check_timeout", "timeout of each readiness check",
+		func(c *Config) *time.Duration { return &c.Health.CheckTimeout }),
+	floatSetting("health.max_pool_usage", "share of open database connections in use before the server isn't ready",
+		func(c *Config) *float64 { return &c.Health.MaxPoolUsage }),
+	durationSetting("metrics.catalog_interval", "how often the catalog size gauges are recounted",
+		func(c *Config) *time.Duration { return &c.Metrics.CatalogInterval }),
+	stringSetting("log.level", "log level: debug, info, warn or error", func(c *Config) *string { return &c.Log.Level }),
+	stringSetting("log.format", "log format: json or text", func(c *Config) *string { return &c.Log.Format }),
+	floatSetting("log.sample_rate", "share of successful requests written to the access log",
+		func(c *Config) *float64 { return &c.Log.SampleRate }),
+	stringSetting("tracing.exporter", "span exporter: none, stdout or otlp", func(c *Config) *string { return &c.Tracing.Exporter }),
+	stringSetting("tracing.endpoint", "OTLP/HTTP collector host:port", func(c *Config) *string { return &c.Tracing.Endpoint }),
+	boolSetting("tracing.insecure", "send spans to the collector without TLS", func(c *Config) *bool { return &c.Tracing.Insecure }),
+	stringSetting("tracing.service_name", "service name reported in spans", func(c *Config) *string { return &c.Tracing.ServiceName }),
+	floatSetting("tracing.sample_ratio", "share of new traces that are sampled",
+		func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+	{key: "auth.signing_keys", usage: `comma-separated "kid:secret" token signing keys`, set: func(c *Config, value string) error {
+		c.Auth.SigningKeys = Secret(value)
+		return nil
+	}},
+	stringSetting("auth.signing_key_id", "id of the key new tokens are signed with",
+		func(c *Config) *string { return &c.Auth.SigningKeyID }),
+	stringSetting("auth.issuer", "issuer of the access tokens", func(c *Config) *string { return &c.Auth.Issuer }),
+	durationSetting("auth.access_ttl", "lifetime of access tokens", func(c *Config) *time.Duration { return &c.Auth.AccessTTL }),
+	durationSetting("auth.refresh_ttl", "lifetime of refresh tokens since their last use",
+		func(c *Config) *time.Duration { return &c.Auth.RefreshTTL }),
+	durationSetting("circulation.loan_period", "time from checkout until a loan is due",
+		func(c *Config) *time.Duration { return &c.Circulation.LoanPeriod }),
+	intSetting("circulation.loan_limit", "maximum number of copies a member may have on loan",
+		func(c *Config) *int { return &c.Circulation.LoanLimit }),
+	intSetting("circulation.max_renewals", "how many times a loan can be renewed",
+		func(c *Config) *int { return &c.Circulation.MaxRenewals }),
+	durationSetting("circulation.renewal_grace", "how long after its due date a loan can still be renewed",
+		func(c *Config) *time.Duration { return &c.Circulation.RenewalGrace }),
+	durationSetting("circulation.pickup_window", "time a member has to pick up a copy held for them",
+		func(c *Config) *time.Duration { return &c.Circulation.PickupWindow }),
+	durationSetting("circulation.hold_expiry_interval", "how often missed pickups are passed on to the next hold",
+		func(c *Config) *time.Duration { return &c.Circulation.HoldExpiryInterval }),
+	stringSetting("db.driver", "database driver: postgres or sqlite", func(c *Config) *string { return &c.DB.Driver }),
+	stringSetting("db.path", "SQLite database file", func(c *Config) *string { return &c.DB.Path }),
+	stringSetting("db.host", "database host", func(c *Config) *string { return &c.DB.Host }),
+	intSetting("db.port", "database port", func(c *Config) *int { return &c.DB.Port }),
+	stringSetting("db.user", "database user", func(c *Config) *string { return &c.DB.User }),
+	{key: "db.password", usage: "database password", set: func(c *Config, value string) error {
+		c.DB.Password = Secret(value)
+		return nil
+	}},
+	stringSetting("db.name", "database name", func(c *Config) *string { return &c.DB.Name }),
+	stringSetting("db.sslmode", "database SSL mode", func(c *Config) *string { return &c.DB.SSLMode }),
+	intSetting("db.max_open_conns", "maximum open database connections", func(c *Config) *int { return &c.DB.MaxOpenConns }),
+	intSetting("db.max_idle_conns", "maximum idle database connections", func(c *Config) *int { return &c.DB.MaxIdleConns }),
+	durationSetting("db.conn_max_lifetime", "maximum database connection lifetime",
+		func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime }),
+	stringSetting("db.log_level", "SQL log level: silent, error, warn or info", func(c *Config) *string { return &c.DB.LogLevel }),
+	durationSetting("db.slow_threshold", "SQL statements slower than this are logged as warnings",
+		func(c *Config) *time.Duration { return &c.DB.SlowThreshold }),
+	boolSetting("db.log_params", "log SQL parameter values instead of placeholders", func(c *Config) *bool { return &c.DB.LogParams }),
+}
+
+// Load builds the configuration from, in increasing precedence, the
+// defaults, the YAML file named by -config or LIBRARY_CONFIG, the
+// environment and the command line flags. It returns the arguments
+// left after the flags.
+func Load(args []string) (cfg Config, rest []string, err error) {
+	cfg = Default()
+
+	fs := flag.NewFlagSet("library_api", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "YAML config file (env "+envPrefix+"CONFIG)")
+	flags := map[string]string{}
+	for _, s := range settings {
+		define := fs.Func
+		if s.isBool {
+			define = fs.BoolFunc
+		}
+		define(s.flag(), fmt.Sprintf("%s (env %s)", s.usage, s.env()), func(value string) error {
+			flags[s.key] = value
+			return nil
+		})
+	}
+	if err = fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *path != "" {
+		if err = loadFile(&cfg, *path); err != nil {
+			return cfg, nil, fmt.Errorf("config file %s: %w", *path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			if err = s.set(&cfg, value); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %w", describe(s.key), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flags[s.key]; ok {
+			if err = s.set(&cfg, value); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %w", describe(s.key), err)
+			}
+		}
+	}
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func loadFile(cfg *Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// describe names a setting together with its environment variable and
+// flag, so errors point at whichever source the user set it in.
+func describe(key string) string {
+	s := setting{key: key}
+	return fmt.Sprintf("%s (%s, -%s)", key, s.env(), s.flag())
+}
+
+func stringSetting(key, usage string, field func(c *Config) *string) setting {
+	return setting{key: key, usage: usage, set: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func intSetting(key, usage string, field func(c *Config) *int) setting {
+	return setting{key: key, usage: usage, set: func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func boolSetting(key, usage string, field func(c *Config) *bool) setting {
+	return setting{key: key, usage: usage, isBool: true, set: func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func floatSetting(key, usage string, field func(c *Config) *float64) setting {
+	return setting{key: key, usage: usage, set: func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+func durationSetting(key, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{key: key, usage: usage, set: func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// sqlitePragmas are run on every new SQLite connection. Foreign keys
+// are off by default in SQLite, WAL lets readers work while a write is
+// in progress and the busy timeout makes writers wait for each other.
+var sqlitePragmas = []string{"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"}
+
+// Open connects to the database selected by cfg.Driver.
+func Open(cfg config.DB, gormCfg *gorm.Config) (*gorm.DB, error) {
+	if cfg.Driver != "sqlite" {
+		return gorm.Open(postgres.Open(cfg.DSN()), gormCfg)
+	}
+
+	// SQLite compares timestamps as text, so they are all written in
+	// UTC: the current time as well as the times of created and updated
+	// rows. Transactions take the write lock up front, otherwise two of
+	// them reading before writing fail instead of waiting.
+	if gormCfg.NowFunc == nil {
+		gormCfg.NowFunc = func() time.Time { return time.Now().UTC() }
+	}
+	query := url.Values{"_pragma": sqlitePragmas, "_txlock": {"immediate"}}
+	db, err := gorm.Open(sqlite.Open(cfg.Path+"?"+query.Encode()), gormCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("database:utc_create", utcTimes),
+		cb.Update().Before("gorm:update").Register("database:utc_update", utcTimes),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// utcTimes converts the times a statement writes to UTC, in the
+// structs being created or updated and in column maps. Associations
+// are written by statements of their own.
+func utcTimes(db *gorm.DB) {
+	switch dest := db.Statement.Dest.(type) {
+	case map[string]any:
+		for column, v := range dest {
+			dest[column] = utcValue(v)
+		}
+	case []map[string]any:
+		for _, row := range dest {
+			for column, v := range row {
+				row[column] = utcValue(v)
+			}
+		}
+	default:
+		v := reflect.ValueOf(dest)
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			if !v.CanSet() {
+				// Updates of a struct passed by value write a copy.
+				cp := reflect.New(v.Type())
+				cp.Elem().Set(v)
+				v = cp.Elem()
+				db.Statement.Dest = cp.Interface()
+			}
+			utcFields(v)
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				item := v.Index(i)
+				for item.Kind() == reflect.Pointer && !item.IsNil() {
+					item = item.Elem()
+				}
+				if item.Kind() == reflect.Struct {
+					utcFields(item)
+				}
+			}
+		}
+	}
+}
+
+func utcValue(v any) any {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case *time.Time:
+		if t != nil {
+			return t.UTC()
+		}
+	}
+	return v
+}
+
+// utcFields converts the time fields of the struct v, including those
+// of nested structs like gorm.DeletedAt, but not of associations.
+func utcFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Type() == timeType:
+			field.Set(reflect.ValueOf(field.Interface().(time.Time).UTC()))
+		case field.Type() == reflect.PointerTo(timeType) && !field.IsNil():
+			t := field.Interface().(*time.Time).UTC()
+			field.Set(reflect.ValueOf(&t))
+		case field.Kind() == reflect.Struct:
+			utcFields(field)
+		}
+	}
+}
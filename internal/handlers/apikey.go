@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultKeyOverlap is how long a rotated key keeps working unless the
+// rotation asks otherwise.
+const defaultKeyOverlap = 24 * time.Hour
+
+type APIKeyHandler struct {
+	repository *repository.APIKeyRepository
+}
+
+func NewAPIKeyHandler(r *repository.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{repository: r}
+}
+
+// ListAPIKeys godoc
+// @Summary Get all API keys
+// @Description Get all API keys, including expired and revoked ones. Keys themselves are never returned.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (kh APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "APIKeyHandler.ListAPIKeys")
+	defer span.End()
+
+	keys, err := kh.repository.ReadAll(ctx)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// GetAPIKey godoc
+// @Summary Get API key by ID
+// @Description Get the scopes, expiry and last use of an API key
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "API key not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api-keys/{id} [get]
+func (kh APIKeyHandler) GetAPIKey(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "APIKeyHandler.GetAPIKey")
+	defer span.End()
+
+	id := c.Param("id")
+	key, err := kh.repository.Read(ctx, id)
+	if err != nil {
+		return repositoryError(err, apiKeyNotFound(id))
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create an API key with the given scopes. The key is only returned in this response, clients send it as "Authorization: ApiKey <key>".
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.NewAPIKey true "Name, scopes and optional expiry"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 422 {object} problem.Problem "Missing name, unknown scopes or expiry in the past"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (kh APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "APIKeyHandler.CreateAPIKey")
+	defer span.End()
+
+	var req models.NewAPIKey
+	if err := c.Bind(&req); err != nil {
+		return invalidBody()
+	}
+
+	errs := fieldErrors(&req)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, problem.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	if len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	id, secret, hash := auth.NewAPIKey()
+	key := models.APIKey{ID: id, Name: req.Name, Hash: hash, CreatedBy: principalUserID(c), ExpiresAt: req.ExpiresAt}
+	for _, scope := range req.Scopes {
+		key.Scopes = append(key.Scopes, models.APIKeyScope{Scope: scope})
+	}
+
+	if err := kh.repository.Create(ctx, &key); err != nil {
+		return repositoryError(err, nil)
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusCreated, models.CreatedAPIKey{APIKey: key, Key: secret})
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Create a successor with the same name and scopes. The old key keeps working for the overlap window (24 hours by default), then expires.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param rotation body models.APIKeyRotation false "Overlap window and expiry of the new key"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "API key not found"
+// @Failure 409 {object} problem.Problem "API key expired, was revoked or was already rotated"
+// @Failure 422 {object} problem.Problem "Invalid overlap or expiry in the past"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api-keys/{id}/rotate [post]
+func (kh APIKeyHandler) RotateAPIKey(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "APIKeyHandler.RotateAPIKey")
+	defer span.End()
+
+	var req models.APIKeyRotation
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return invalidBody()
+		}
+	}
+
+	errs := fieldErrors(&req)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, problem.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	if len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	overlap := defaultKeyOverlap
+	if req.OverlapSeconds != nil {
+		overlap = time.Duration(*req.OverlapSeconds) * time.Second
+	}
+
+	id := c.Param("id")
+	newID, secret, hash := auth.NewAPIKey()
+	key := models.APIKey{ID: newID, Hash: hash, CreatedBy: principalUserID(c), ExpiresAt: req.ExpiresAt}
+	_, err := kh.repository.Rotate(ctx, id, &key, overlap)
+	if errors.Is(err, repository.ErrAPIKeyInactive) {
+		return problem.New(http.StatusConflict, problem.CodeAPIKeyInactive, "The API key expired, was revoked or was already rotated.")
+	}
+	if err != nil {
+		return repositoryError(err, apiKeyNotFound(id))
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusCreated, models.CreatedAPIKey{APIKey: key, Key: secret})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Stop accepting an API key immediately. The key stays listed as revoked.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 204 "No content"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "API key not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (kh APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "APIKeyHandler.RevokeAPIKey")
+	defer span.End()
+
+	id := c.Param("id")
+	if err := kh.repository.Revoke(ctx, id); err != nil {
+		return repositoryError(err, apiKeyNotFound(id))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func principalUserID(c echo.Context) uint {
+	p, _ := auth.PrincipalFrom(c.Request().Context())
+	return p.UserID
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AuthHandler struct {
+	users      *repository.UserRepository
+	sessions   *repository.SessionRepository
+	tokens     *auth.Tokens
+	refreshTTL time.Duration
+}
+
+func NewAuthHandler(users *repository.UserRepository, sessions *repository.SessionRepository, tokens *auth.Tokens, refreshTTL time.Duration) *AuthHandler {
+	return &AuthHandler{users: users, sessions: sessions, tokens: tokens, refreshTTL: refreshTTL}
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchange a username and password for an access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.Credentials true "Username and password"
+// @Success 200 {object} models.Tokens
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Wrong username or password"
+// @Failure 422 {object} problem.Problem "Missing username or password"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /auth/login [post]
+func (ah AuthHandler) Login(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "AuthHandler.Login")
+	defer span.End()
+
+	var credentials models.Credentials
+	err := c.Bind(&credentials)
+	if err != nil {
+		return invalidBody()
+	}
+
+	if errs := fieldErrors(&credentials); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	user, err := ah.users.ReadByUsername(ctx, credentials.Username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return repositoryError(err, nil)
+	}
+
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, credentials.Password) {
+		return problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Wrong username or password.")
+	}
+
+	session := models.Session{ID: auth.NewSessionID(), UserID: user.ID}
+	refreshToken, refreshHash := auth.NewRefreshToken(session.ID)
+	session.RefreshHash = refreshHash
+	err = ah.sessions.Create(ctx, &session, ah.refreshTTL)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	return ah.respond(c, &session, refreshToken)
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once, reusing one ends the session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.Tokens
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Refresh token is invalid, expired or revoked"
+// @Failure 422 {object} problem.Problem "Missing refresh token"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /auth/refresh [post]
+func (ah AuthHandler) Refresh(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "AuthHandler.Refresh")
+	defer span.End()
+
+	sessionID, hash, err := parseRefreshRequest(c)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, newHash := auth.NewRefreshToken(sessionID)
+	session, err := ah.sessions.Rotate(ctx, sessionID, hash, newHash, ah.refreshTTL)
+	if errors.Is(err, repository.ErrSessionEnded) {
+		return invalidRefreshToken()
+	}
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	return ah.respond(c, session, refreshToken)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the session of a refresh token, its access tokens stop working immediately
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh token"
+// @Success 204 "No content"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Malformed refresh token"
+// @Failure 422 {object} problem.Problem "Missing refresh token"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /auth/logout [post]
+func (ah AuthHandler) Logout(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "AuthHandler.Logout")
+	defer span.End()
+
+	sessionID, hash, err := parseRefreshRequest(c)
+	if err != nil {
+		return err
+	}
+
+	// Logging out of a session that already ended succeeds.
+	err = ah.sessions.Revoke(ctx, sessionID, hash)
+	if err != nil && !errors.Is(err, repository.ErrSessionEnded) {
+		return repositoryError(err, nil)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ah AuthHandler) respond(c echo.Context, session *models.Session, refreshToken string) error {
+	accessToken, expiresAt, err := ah.tokens.Issue(session.UserID, session.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, models.Tokens{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Round(time.Second).Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+func parseRefreshRequest(c echo.Context) (sessionID, hash string, err error) {
+	var req models.RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return "", "", invalidBody()
+	}
+
+	if errs := fieldErrors(&req); len(errs) > 0 {
+		return "", "", validationFailed(errs)
+	}
+
+	sessionID, hash, err = auth.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		return "", "", invalidRefreshToken()
+	}
+	return sessionID, hash, nil
+}
+
+func invalidRefreshToken() *problem.Problem {
+	return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "The refresh token is invalid or expired.")
+}
@@ -1,23 +1,25 @@
 package handlers
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"net/http"
-	"strconv"
+	"slices"
 
+	"github.com/4otis/library_api_2025/internal/auth"
 	"github.com/4otis/library_api_2025/internal/models"
 	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type AuthorHandler struct {
-	repository *repository.AuthorRepository
+	repository      repository.AuthorStore
+	maxIncludeDepth int
 }
 
-func NewAuthorHandler(r *repository.AuthorRepository) *AuthorHandler {
-	return &AuthorHandler{repository: r}
+func NewAuthorHandler(r repository.AuthorStore, maxIncludeDepth int) *AuthorHandler {
+	return &AuthorHandler{repository: r, maxIncludeDepth: maxIncludeDepth}
 }
 
 // ListAuthors godoc
@@ -26,15 +28,51 @@ func NewAuthorHandler(r *repository.AuthorRepository) *AuthorHandler {
 // @Tags authors
 // @Accept  json
 // @Produce  json
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param name query string false "Exact name"
+// @Param name~ query string false "Name substring, case-insensitive"
+// @Param book_id query int false "Only authors of this book"
+// @Param created_after query string false "Created after (RFC 3339 or date)"
+// @Param created_before query string false "Created before (RFC 3339 or date)"
+// @Param updated_after query string false "Updated after (RFC 3339 or date)"
+// @Param updated_before query string false "Updated before (RFC 3339 or date)"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Param fields query string false "Comma-separated fields to return, e.g. name"
+// @Param include query string false "Comma-separated associations to expand, e.g. books,books.authors (default books)"
 // @Success 200 {array} models.Author
+// @Header 200 {integer} X-Total-Count "Total number of authors"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /authors [get]
 func (ah AuthorHandler) ListAuthors(c echo.Context) error {
-	authors, err := ah.repository.ReadAll()
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.ListAuthors")
+	defer span.End()
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	exp, err := parseExpansion(c, resourceAuthor, ah.maxIncludeDepth)
+	if err != nil {
+		return err
+	}
+	q.Preloads = exp.preloads
+
+	authors, page, err := ah.repository.ReadAll(ctx, q)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return repositoryError(err, nil)
 	}
 
-	return c.JSON(http.StatusOK, authors)
+	setPageHeaders(c, q, page)
+	return exp.render(c, http.StatusOK, authors)
 }
 
 // GetAuthor godoc
@@ -44,87 +82,198 @@ func (ah AuthorHandler) ListAuthors(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param id path int true "Author ID"
+// @Param fields query string false "Comma-separated fields to return, e.g. name"
+// @Param include query string false "Comma-separated associations to expand, e.g. books,books.authors (default books)"
 // @Success 200 {object} models.Author
-// @Failure 400 {object} map[string]string "Invalid ID format"
-// @Failure 404 {object} map[string]string "Author not found"
+// @Header 200 {string} ETag "Current version of the author"
+// @Failure 400 {object} problem.Problem "Invalid ID format, fields or include"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Author not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /authors/{id} [get]
 func (ah AuthorHandler) GetAuthor(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.GetAuthor")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
 	}
 
-	author, err := ah.repository.Read(uint(id))
+	exp, err := parseExpansion(c, resourceAuthor, ah.maxIncludeDepth)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Error. Author not found (by id: %d).", id))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, author)
+	author, err := ah.repository.Read(ctx, id, exp.preloads...)
+	if err != nil {
+		return repositoryError(err, authorNotFound(id))
+	}
+
+	setETag(c, author.Version)
+	return exp.render(c, http.StatusOK, author)
 }
 
 // CreateAuthor godoc
 // @Summary Create a new author
-// @Description Add a new author to the system
+// @Description Add a new author to the system, its ID, version and timestamps are assigned by the server
 // @Tags authors
 // @Accept json
 // @Produce json
 // @Param author body models.Author true "Author data"
 // @Success 201 {object} models.Author
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Header 201 {string} ETag "Current version of the author"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 409 {object} problem.Problem "Author already exists"
+// @Failure 422 {object} problem.Problem "Author data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /authors [post]
 func (ah AuthorHandler) CreateAuthor(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.CreateAuthor")
+	defer span.End()
+
 	var author models.Author
 	err := c.Bind(&author)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid request body.")
+		return invalidBody()
+	}
+	// The ID, version and timestamps are assigned on create, not by
+	// the client.
+	author.Model = gorm.Model{}
+	author.Version = 0
+
+	err = validateAuthor(ctx, &author)
+	if err != nil {
+		return err
 	}
 
-	err = ah.repository.Create(&author)
+	err = ah.repository.Create(ctx, &author)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return repositoryError(err, nil)
 	}
 
+	setETag(c, author.Version)
 	return c.JSON(http.StatusCreated, author)
 }
 
 // UpdateAuthor godoc
-// @Summary Update author information
-// @Description Update existing author's data
+// @Summary Replace author information
+// @Description Replace existing author's data, omitted fields are reset and omitted books are detached
 // @Tags authors
 // @Accept json
 // @Produce json
 // @Param id path int true "Author ID"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param author body models.Author true "Updated author data"
 // @Success 204 "No content"
-// @Failure 400 {object} map[string]string "Invalid ID format or request body"
-// @Failure 404 {object} map[string]string "Author not found by entered id"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Header 204 {string} ETag "New version of the author"
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Author not found by entered id"
+// @Failure 412 {object} problem.Problem "Author was modified since the If-Match version"
+// @Failure 422 {object} problem.Problem "Author data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /authors/{id} [put]
 func (ah AuthorHandler) UpdateAuthor(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.UpdateAuthor")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
 	}
 
 	var author models.Author
 	err = c.Bind(&author)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid request body.")
+		return invalidBody()
 	}
 
-	err = ah.repository.Update(uint(id), &author)
+	err = validateAuthor(ctx, &author)
 	if err != nil {
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Error. Author not found (by id: %d).", id))
-		default:
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		}
+		return err
 	}
+
+	err = ah.repository.Update(ctx, id, version, &author)
+	if err != nil {
+		return repositoryError(err, authorNotFound(id))
+	}
+
+	setETag(c, author.Version)
 	return c.NoContent(http.StatusNoContent)
 }
 
+// PatchAuthor godoc
+// @Summary Partially update author information
+// @Description Apply a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to the author
+// @Tags authors
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Author ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "Merge patch object or JSON patch operations"
+// @Success 200 {object} models.Author
+// @Header 200 {string} ETag "New version of the author"
+// @Failure 400 {object} problem.Problem "Invalid ID format or patch document"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Author not found by entered id"
+// @Failure 409 {object} problem.Problem "Patch test operation failed"
+// @Failure 412 {object} problem.Problem "Author was modified since the If-Match version"
+// @Failure 415 {object} problem.Problem "Unsupported patch format"
+// @Failure 422 {object} problem.Problem "Patch can't be applied or the patched author is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /authors/{id} [patch]
+func (ah AuthorHandler) PatchAuthor(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.PatchAuthor")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	patch, err := newPatcher(c)
+	if err != nil {
+		return err
+	}
+
+	author, err := ah.repository.Patch(ctx, id, version, func(author *models.Author) error {
+		if err := applyPatch(patch, author); err != nil {
+			return err
+		}
+		return validateAuthor(ctx, author)
+	})
+	if err != nil {
+		return repositoryError(err, authorNotFound(id))
+	}
+
+	setETag(c, author.Version)
+	return c.JSON(http.StatusOK, author)
+}
+
 // DeleteAuthor godoc
 // @Summary Delete an author
 // @Description Remove author from the system
@@ -132,20 +281,51 @@ func (ah AuthorHandler) UpdateAuthor(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param id path int true "Author ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 204 "No content"
-// @Failure 400 {object} map[string]string "Invalid ID format"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Author not found by entered id"
+// @Failure 412 {object} problem.Problem "Author was modified since the If-Match version"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /authors/{id} [delete]
 func (ah AuthorHandler) DeleteAuthor(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "AuthorHandler.DeleteAuthor")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
 	}
 
-	err = ah.repository.Delete(uint(id))
+	version, err := ifMatch(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+
+	err = ah.repository.Delete(ctx, id, version)
+	if err != nil {
+		return repositoryError(err, authorNotFound(id))
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// validateAuthor checks author against the model rules. The author's
+// own ID isn't validated, it comes from the path or is assigned on
+// create.
+func validateAuthor(ctx context.Context, author *models.Author) error {
+	v := *author
+	v.ID = 0
+	if errs := fieldErrors(&v); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	if slices.ContainsFunc(author.Books, func(b *models.Book) bool { return b.ID == 0 }) {
+		return requireNested(ctx, auth.BooksWrite)
+	}
+	return nil
+}
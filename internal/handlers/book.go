@@ -1,23 +1,27 @@
 package handlers
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net/http"
-	"strconv"
+	"slices"
 
+	"github.com/4otis/library_api_2025/internal/auth"
 	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
 	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type BookHandler struct {
-	repository *repository.BookRepository
+	repository      repository.BookStore
+	maxIncludeDepth int
 }
 
-func NewBookHandler(r *repository.BookRepository) *BookHandler {
-	return &BookHandler{repository: r}
+func NewBookHandler(r repository.BookStore, maxIncludeDepth int) *BookHandler {
+	return &BookHandler{repository: r, maxIncludeDepth: maxIncludeDepth}
 }
 
 // ListBooks godoc
@@ -26,104 +30,262 @@ func NewBookHandler(r *repository.BookRepository) *BookHandler {
 // @Tags books
 // @Accept  json
 // @Produce  json
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param title query string false "Exact title"
+// @Param title~ query string false "Title substring, case-insensitive"
+// @Param pages_min query int false "Minimum number of pages"
+// @Param pages_max query int false "Maximum number of pages"
+// @Param author_id query int false "Only books by this author"
+// @Param created_after query string false "Created after (RFC 3339 or date)"
+// @Param created_before query string false "Created before (RFC 3339 or date)"
+// @Param updated_after query string false "Updated after (RFC 3339 or date)"
+// @Param updated_before query string false "Updated before (RFC 3339 or date)"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Param fields query string false "Comma-separated fields to return, e.g. title,pages"
+// @Param include query string false "Comma-separated associations to expand, e.g. authors,authors.books (default authors)"
 // @Success 200 {array} models.Book
+// @Header 200 {integer} X-Total-Count "Total number of books"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /books [get]
 func (bh BookHandler) ListBooks(c echo.Context) error {
-	books, err := bh.repository.ReadAll()
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.ListBooks")
+	defer span.End()
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	exp, err := parseExpansion(c, resourceBook, bh.maxIncludeDepth)
+	if err != nil {
+		return err
+	}
+	q.Preloads = exp.preloads
+
+	books, page, err := bh.repository.ReadAll(ctx, q)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return repositoryError(err, nil)
 	}
-	return c.JSON(http.StatusOK, books)
+	setPageHeaders(c, q, page)
+	return exp.render(c, http.StatusOK, books)
 }
 
 // GetBook godoc
 // @Summary Get book by ID
-// @Description Get detailed information about a specific book
+// @Description Get detailed information about a specific book, with the number of its copies by status
 // @Tags books
 // @Accept json
 // @Produce json
 // @Param id path int true "Book ID"
+// @Param fields query string false "Comma-separated fields to return, e.g. title,pages"
+// @Param include query string false "Comma-separated associations to expand, e.g. authors,authors.books (default authors)"
 // @Success 200 {object} models.Book
-// @Failure 400 {object} map[string]string "Invalid ID format"
-// @Failure 404 {object} map[string]string "Book not found"
+// @Header 200 {string} ETag "Current version of the book"
+// @Failure 400 {object} problem.Problem "Invalid ID format, fields or include"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /books/{id} [get]
 func (bh BookHandler) GetBook(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.GetBook")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
 	}
 
-	book, err := bh.repository.Read(uint(id))
+	exp, err := parseExpansion(c, resourceBook, bh.maxIncludeDepth)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Error. Book not found (by id: %d).", id))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, book)
+	book, err := bh.repository.Read(ctx, id, exp.preloads...)
+	if err != nil {
+		return repositoryError(err, bookNotFound(id))
+	}
+
+	book.Availability, err = bh.repository.Availability(ctx, id)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	setETag(c, book.Version)
+	return exp.render(c, http.StatusOK, book)
 }
 
 // CreateBook godoc
 // @Summary Create a new book
-// @Description Add a new book to the library
+// @Description Add a new book to the library, its ID, version and timestamps are assigned by the server
 // @Tags books
 // @Accept json
 // @Produce json
 // @Param book body models.Book true "Book data"
 // @Success 201 {object} models.Book
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Header 201 {string} ETag "Current version of the book"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 409 {object} problem.Problem "Book already exists"
+// @Failure 422 {object} problem.Problem "Book data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /books [post]
 func (bh BookHandler) CreateBook(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.CreateBook")
+	defer span.End()
+
 	var book models.Book
 	err := c.Bind(&book)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid request body.")
+		return invalidBody()
+	}
+	// The ID, version and timestamps are assigned on create, not by
+	// the client.
+	book.Model = gorm.Model{}
+	book.Version = 0
+
+	err = bh.validate(ctx, &book)
+	if err != nil {
+		return err
 	}
 
-	err = bh.repository.Create(&book)
+	err = bh.repository.Create(ctx, &book)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return repositoryError(err, nil)
 	}
 
+	setETag(c, book.Version)
 	return c.JSON(http.StatusCreated, book)
 }
 
 // UpdateBook godoc
-// @Summary Update book information
-// @Description Update existing book's data
+// @Summary Replace book information
+// @Description Replace existing book's data, omitted fields are reset and omitted authors are detached
 // @Tags books
 // @Accept json
 // @Produce json
 // @Param id path int true "Book ID"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param book body models.Book true "Updated book data"
 // @Success 204 "No content"
-// @Failure 400 {object} map[string]string "Invalid ID format or request body"
-// @Failure 404 {object} map[string]string "Book not found by entered id"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Header 204 {string} ETag "New version of the book"
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found by entered id"
+// @Failure 412 {object} problem.Problem "Book was modified since the If-Match version"
+// @Failure 422 {object} problem.Problem "Book data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /books/{id} [put]
 func (bh BookHandler) UpdateBook(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.UpdateBook")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
 	}
 
 	var book models.Book
 	err = c.Bind(&book)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid request body.")
+		return invalidBody()
 	}
 
-	err = bh.repository.Update(uint(id), &book)
+	err = bh.validate(ctx, &book)
 	if err != nil {
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Error. Book not found (by id: %d).", id))
-		default:
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		}
+		return err
 	}
+
+	err = bh.repository.Update(ctx, id, version, &book)
+	if err != nil {
+		return repositoryError(err, bookNotFound(id))
+	}
+
+	setETag(c, book.Version)
 	return c.NoContent(http.StatusNoContent)
 }
 
+// PatchBook godoc
+// @Summary Partially update book information
+// @Description Apply a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to the book. Existing authors are linked by ID and can't be edited through the book, authors without an ID are created
+// @Tags books
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "Merge patch object or JSON patch operations"
+// @Success 200 {object} models.Book
+// @Header 200 {string} ETag "New version of the book"
+// @Failure 400 {object} problem.Problem "Invalid ID format or patch document"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found by entered id"
+// @Failure 409 {object} problem.Problem "Patch test operation failed"
+// @Failure 412 {object} problem.Problem "Book was modified since the If-Match version"
+// @Failure 415 {object} problem.Problem "Unsupported patch format"
+// @Failure 422 {object} problem.Problem "Patch can't be applied or the patched book is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /books/{id} [patch]
+func (bh BookHandler) PatchBook(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.PatchBook")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	patch, err := newPatcher(c)
+	if err != nil {
+		return err
+	}
+
+	book, err := bh.repository.Patch(ctx, id, version, func(book *models.Book) error {
+		linked := book.Authors
+		if err := applyPatch(patch, book); err != nil {
+			return err
+		}
+		if errs := authorChanges(linked, book.Authors); len(errs) > 0 {
+			return validationFailed(errs)
+		}
+		return bh.validate(ctx, book)
+	})
+	if err != nil {
+		return repositoryError(err, bookNotFound(id))
+	}
+
+	setETag(c, book.Version)
+	return c.JSON(http.StatusOK, book)
+}
+
 // DeleteBook godoc
 // @Summary Delete a book
 // @Description Remove book from the library
@@ -131,20 +293,103 @@ func (bh BookHandler) UpdateBook(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param id path int true "Book ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 204 "No content"
-// @Failure 400 {object} map[string]string "Invalid ID format"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found by entered id"
+// @Failure 412 {object} problem.Problem "Book was modified since the If-Match version"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /books/{id} [delete]
 func (bh BookHandler) DeleteBook(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	ctx, span := tracing.Start(c.Request().Context(), "BookHandler.DeleteBook")
+	defer span.End()
+
+	id, err := parseID(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Error. Invalid ID format.")
+		return err
 	}
 
-	err = bh.repository.Delete(uint(id))
+	version, err := ifMatch(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+
+	err = bh.repository.Delete(ctx, id, version)
+	if err != nil {
+		return repositoryError(err, bookNotFound(id))
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// validate checks book against the model rules and makes sure every
+// referenced author exists. The book's own ID isn't validated, it comes
+// from the path or is assigned on create.
+func (bh BookHandler) validate(ctx context.Context, book *models.Book) error {
+	v := *book
+	v.ID = 0
+	errs := fieldErrors(&v)
+
+	var ids []uint
+	for _, author := range book.Authors {
+		if author != nil && author.ID != 0 {
+			ids = append(ids, author.ID)
+		}
+	}
+
+	missing, err := bh.repository.MissingAuthors(ctx, ids)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+	for i, author := range book.Authors {
+		if author != nil && slices.Contains(missing, author.ID) {
+			errs = append(errs, problem.FieldError{
+				Field:   fmt.Sprintf("authors[%d].ID", i),
+				Message: fmt.Sprintf("author %d doesn't exist", author.ID),
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	if slices.ContainsFunc(book.Authors, func(a *models.Author) bool { return a.ID == 0 }) {
+		return requireNested(ctx, auth.AuthorsWrite)
+	}
+	return nil
+}
+
+// authorChanges reports the existing authors a patch tries to edit
+// through the book. They're only linked by ID, so a changed name would
+// be dropped silently; authors without an ID are still created.
+func authorChanges(linked, patched []*models.Author) []problem.FieldError {
+	names := make(map[uint]string, len(linked))
+	for _, author := range linked {
+		names[author.ID] = author.Name
+	}
+
+	var errs []problem.FieldError
+	for i, author := range patched {
+		if author == nil || author.ID == 0 {
+			continue
+		}
+		if author.Name != names[author.ID] {
+			errs = append(errs, problem.FieldError{
+				Field:   fmt.Sprintf("authors[%d].name", i),
+				Message: "can't be changed through a book, patch the author instead",
+			})
+		}
+		if len(author.Books) > 0 {
+			errs = append(errs, problem.FieldError{
+				Field:   fmt.Sprintf("authors[%d].books", i),
+				Message: "can't be changed through a book, patch the author instead",
+			})
+		}
+	}
+	return errs
+}
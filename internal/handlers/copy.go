@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+type CopyHandler struct {
+	repository *repository.CopyRepository
+}
+
+func NewCopyHandler(r *repository.CopyRepository) *CopyHandler {
+	return &CopyHandler{repository: r}
+}
+
+// ListCopies godoc
+// @Summary Get the copies of a book
+// @Description Get the physical copies of a book
+// @Tags copies
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param barcode query string false "Exact barcode"
+// @Param location query string false "Exact shelf location"
+// @Param location~ query string false "Shelf location substring, case-insensitive"
+// @Param condition query string false "Condition: new, good, fair, poor or damaged"
+// @Param status query string false "Status: available, on_loan, on_hold, in_transit, lost or withdrawn"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Copy
+// @Header 200 {integer} X-Total-Count "Total number of copies"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid ID format, pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /books/{id}/copies [get]
+func (ch CopyHandler) ListCopies(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "CopyHandler.ListCopies")
+	defer span.End()
+
+	bookID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	copies, page, err := ch.repository.ReadAll(ctx, bookID, q)
+	if err != nil {
+		return copyError(err, bookID, 0)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, copies)
+}
+
+// GetCopy godoc
+// @Summary Get copy by ID
+// @Description Get a physical copy of a book
+// @Tags copies
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param copy_id path int true "Copy ID"
+// @Success 200 {object} models.Copy
+// @Header 200 {string} ETag "Current version of the copy"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book or copy not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /books/{id}/copies/{copy_id} [get]
+func (ch CopyHandler) GetCopy(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "CopyHandler.GetCopy")
+	defer span.End()
+
+	bookID, id, err := parseCopyIDs(c)
+	if err != nil {
+		return err
+	}
+
+	item, err := ch.repository.Read(ctx, bookID, id)
+	if err != nil {
+		return copyError(err, bookID, id)
+	}
+
+	setETag(c, item.Version)
+	return c.JSON(http.StatusOK, item)
+}
+
+// CreateCopy godoc
+// @Summary Add a copy
+// @Description Add a physical copy of a book, condition and status default to good and available. An available copy goes to the first waiting hold instead and is put on hold.
+// @Tags copies
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param copy body models.Copy true "Copy data"
+// @Success 201 {object} models.Copy
+// @Header 201 {string} ETag "Current version of the copy"
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found"
+// @Failure 409 {object} problem.Problem "Barcode is already taken or the status is on_loan or on_hold"
+// @Failure 422 {object} problem.Problem "Copy data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/copies [post]
+func (ch CopyHandler) CreateCopy(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "CopyHandler.CreateCopy")
+	defer span.End()
+
+	bookID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	var item models.Copy
+	err = c.Bind(&item)
+	if err != nil {
+		return invalidBody()
+	}
+
+	err = validateCopy(&item)
+	if err != nil {
+		return err
+	}
+
+	err = ch.repository.Create(ctx, bookID, &item)
+	if err != nil {
+		return copyError(err, bookID, 0)
+	}
+
+	setETag(c, item.Version)
+	return c.JSON(http.StatusCreated, item)
+}
+
+// UpdateCopy godoc
+// @Summary Replace copy information
+// @Description Replace a copy's data, e.g. to move it to another shelf or mark it lost. Omitted fields are reset, except the status, which is kept. Copies can't be moved into or out of on_loan and on_hold, a copy made available goes to the first waiting hold instead.
+// @Tags copies
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param copy_id path int true "Copy ID"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param copy body models.Copy true "Updated copy data"
+// @Success 204 "No content"
+// @Header 204 {string} ETag "New version of the copy"
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book or copy not found"
+// @Failure 409 {object} problem.Problem "Barcode is already taken or the status change is left to circulation"
+// @Failure 412 {object} problem.Problem "Copy was modified since the If-Match version"
+// @Failure 422 {object} problem.Problem "Copy data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/copies/{copy_id} [put]
+func (ch CopyHandler) UpdateCopy(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "CopyHandler.UpdateCopy")
+	defer span.End()
+
+	bookID, id, err := parseCopyIDs(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var item models.Copy
+	err = c.Bind(&item)
+	if err != nil {
+		return invalidBody()
+	}
+
+	err = validateCopy(&item)
+	if err != nil {
+		return err
+	}
+
+	err = ch.repository.Update(ctx, bookID, id, version, &item)
+	if err != nil {
+		return copyError(err, bookID, id)
+	}
+
+	setETag(c, item.Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteCopy godoc
+// @Summary Delete a copy
+// @Description Remove a copy from the inventory. Copies that leave the collection are usually kept as withdrawn instead.
+// @Tags copies
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param copy_id path int true "Copy ID"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 204 "No content"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book or copy not found"
+// @Failure 409 {object} problem.Problem "Copy is on loan or on hold"
+// @Failure 412 {object} problem.Problem "Copy was modified since the If-Match version"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/copies/{copy_id} [delete]
+func (ch CopyHandler) DeleteCopy(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "CopyHandler.DeleteCopy")
+	defer span.End()
+
+	bookID, id, err := parseCopyIDs(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	err = ch.repository.Delete(ctx, bookID, id, version)
+	if err != nil {
+		return copyError(err, bookID, id)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func parseCopyIDs(c echo.Context) (bookID, id uint, err error) {
+	if bookID, err = parseID(c); err != nil {
+		return 0, 0, err
+	}
+	if id, err = parseIDParam(c, "copy_id"); err != nil {
+		return 0, 0, err
+	}
+	return bookID, id, nil
+}
+
+// copyError tells a missing book from a missing copy.
+func copyError(err error, bookID, id uint) error {
+	if errors.Is(err, repository.ErrBookNotFound) {
+		return bookNotFound(bookID)
+	}
+	if errors.Is(err, repository.ErrCirculationStatus) {
+		return problem.New(http.StatusConflict, problem.CodeCirculationStatus,
+			"Copies on loan or on hold are managed by checkouts, returns and holds.")
+	}
+	return repositoryError(err, copyNotFound(id))
+}
+
+// validateCopy fills in the default condition and checks item against
+// the model rules, the status is defaulted by the repository. The
+// copy's own ID and book come from the path.
+func validateCopy(item *models.Copy) error {
+	if item.Condition == "" {
+		item.Condition = models.ConditionGood
+	}
+
+	v := *item
+	v.ID = 0
+	if errs := fieldErrors(&v); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+	return nil
+}
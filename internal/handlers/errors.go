@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ErrorHandler renders every error returned by a handler as an
+// application/problem+json response. Errors that aren't problems are
+// reported as internal errors without exposing their text.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var p *problem.Problem
+	var he *echo.HTTPError
+	switch {
+	case errors.As(err, &p):
+	case errors.As(err, &he) && he.Code < http.StatusInternalServerError:
+		detail := fmt.Sprint(he.Message)
+		if detail == http.StatusText(he.Code) {
+			detail = ""
+		}
+		p = problem.New(he.Code, problem.CodeForStatus(he.Code), detail)
+	default:
+		p = problem.Internal(err)
+	}
+
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request().Context(), "Error. Request failed", "error", err)
+	}
+
+	resp := *p
+	resp.Instance = c.Request().URL.Path
+	c.Response().Header().Set(echo.HeaderContentType, problem.MIMEProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(resp.Status)
+	} else {
+		err = c.JSON(resp.Status, resp)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "Error. Failed to write problem", "error", err)
+	}
+}
+
+// repositoryError maps an error returned by a repository to a problem.
+// notFound is returned for missing records. Problems raised by
+// callbacks running inside a repository are passed through.
+func repositoryError(err error, notFound *problem.Problem) error {
+	var p *problem.Problem
+	var invalid *repository.InvalidQueryError
+	var patchErr *patchError
+	switch {
+	case errors.As(err, &p):
+		return p
+	case notFound != nil && errors.Is(err, gorm.ErrRecordNotFound):
+		return notFound
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return problem.New(http.StatusPreconditionFailed, problem.CodePreconditionFailed,
+			"The resource was modified since the If-Match version.")
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return problem.New(http.StatusConflict, problem.CodeConflict,
+			"The request conflicts with the current state of the resource.")
+	case errors.As(err, &invalid):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidQuery, "Invalid query parameter.").
+			WithErrors(problem.FieldError{Field: invalid.Param, Message: invalid.Reason})
+	case errors.As(err, &patchErr):
+		return patchErr.problem()
+	default:
+		return problem.Internal(err)
+	}
+}
+
+func parseID(c echo.Context) (uint, error) {
+	return parseIDParam(c, "id")
+}
+
+func parseIDParam(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil {
+		return 0, problem.New(http.StatusBadRequest, problem.CodeInvalidID, "Invalid ID format.")
+	}
+	return uint(id), nil
+}
+
+func invalidBody() *problem.Problem {
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body.")
+}
+
+func invalidQuery(param, message string) *problem.Problem {
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidQuery, "Invalid query parameter.").
+		WithErrors(problem.FieldError{Field: param, Message: message})
+}
+
+func bookNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeBookNotFound, "Book not found (by id: %d).", id)
+}
+
+func authorNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeAuthorNotFound, "Author not found (by id: %d).", id)
+}
+
+func userNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeUserNotFound, "User not found (by id: %d).", id)
+}
+
+func apiKeyNotFound(id string) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeAPIKeyNotFound, "API key not found (by id: %s).", id)
+}
+
+func memberNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeMemberNotFound, "Member not found (by id: %d).", id)
+}
+
+func copyNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeCopyNotFound, "Copy not found (by id: %d).", id)
+}
+
+func loanNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeLoanNotFound, "Loan not found (by id: %d).", id)
+}
+
+func holdNotFound(id uint) *problem.Problem {
+	return problem.Newf(http.StatusNotFound, problem.CodeHoldNotFound, "Hold not found (by id: %d).", id)
+}
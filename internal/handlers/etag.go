@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+func setETag(c echo.Context, version uint) {
+	c.Response().Header().Set(headerETag, strconv.Quote(strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatch returns the version required by the If-Match header, or 0
+// when the header is absent or "*". Weak tags never match.
+func ifMatch(c echo.Context) (uint, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, problem.New(http.StatusPreconditionFailed, problem.CodePreconditionFailed,
+			"If-Match must be a single strong entity tag.")
+	}
+
+	version, err := strconv.ParseUint(tag, 10, 0)
+	if err != nil || version == 0 {
+		return 0, problem.New(http.StatusPreconditionFailed, problem.CodePreconditionFailed,
+			"If-Match doesn't match the current version.")
+	}
+
+	return uint(version), nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	resourceBook   = "book"
+	resourceAuthor = "author"
+)
+
+type association struct {
+	preload  string
+	resource string
+}
+
+// resourceSchema lists the JSON fields of a resource and the
+// associations that can be expanded with ?include.
+type resourceSchema struct {
+	fields       []string
+	associations map[string]association
+	include      []string
+}
+
+var resourceSchemas = map[string]resourceSchema{
+	resourceBook: {
+		fields: []string{"ID", "CreatedAt", "UpdatedAt", "DeletedAt", "title", "pages", "version", "availability"},
+		associations: map[string]association{
+			"authors": {preload: "Authors", resource: resourceAuthor},
+		},
+		include: []string{"authors"},
+	},
+	resourceAuthor: {
+		fields: []string{"ID", "CreatedAt", "UpdatedAt", "DeletedAt", "name", "version"},
+		associations: map[string]association{
+			"books": {preload: "Books", resource: resourceBook},
+		},
+		include: []string{"books"},
+	},
+}
+
+// includeTree is a parsed ?include value, e.g. authors.books becomes
+// {"authors": {"books": {}}}.
+type includeTree map[string]includeTree
+
+// expansion describes which part of a resource graph a response has:
+// the selected top-level fields (nil for all of them) and the
+// expanded associations.
+type expansion struct {
+	resource string
+	fields   map[string]bool
+	include  includeTree
+	preloads []string
+}
+
+// parseExpansion reads ?fields and ?include for resource. Include paths
+// may be at most maxDepth associations long.
+func parseExpansion(c echo.Context, resource string, maxDepth int) (exp expansion, err error) {
+	schema := resourceSchemas[resource]
+	exp = expansion{resource: resource, include: includeTree{}}
+
+	if s := c.QueryParam("fields"); s != "" {
+		exp.fields = map[string]bool{"ID": true}
+		for _, field := range strings.Split(s, ",") {
+			if !slices.Contains(schema.fields, field) {
+				return exp, invalidQuery("fields", fmt.Sprintf("unknown field %q", field))
+			}
+			exp.fields[field] = true
+		}
+	}
+
+	paths := schema.include
+	if c.QueryParams().Has("include") {
+		paths = nil
+		if s := c.QueryParam("include"); s != "" {
+			paths = strings.Split(s, ",")
+		}
+	}
+
+	for _, path := range paths {
+		if err := exp.addInclude(path, maxDepth); err != nil {
+			return exp, err
+		}
+	}
+
+	return exp, nil
+}
+
+func (exp *expansion) addInclude(path string, maxDepth int) error {
+	segments := strings.Split(path, ".")
+	if len(segments) > maxDepth {
+		return invalidQuery("include", fmt.Sprintf("%q is deeper than %d levels", path, maxDepth))
+	}
+
+	resource, tree := exp.resource, exp.include
+	var preload []string
+	for _, segment := range segments {
+		assoc, ok := resourceSchemas[resource].associations[segment]
+		if !ok {
+			return invalidQuery("include", fmt.Sprintf("unknown association %q", path))
+		}
+
+		if tree[segment] == nil {
+			tree[segment] = includeTree{}
+		}
+		resource, tree = assoc.resource, tree[segment]
+		preload = append(preload, assoc.preload)
+	}
+
+	exp.preloads = append(exp.preloads, strings.Join(preload, "."))
+	return nil
+}
+
+// render writes v as JSON without the fields and associations the
+// client didn't ask for.
+func (exp expansion) render(c echo.Context, code int, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	prune(doc, exp.resource, exp.fields, exp.include)
+	return c.JSON(code, doc)
+}
+
+func prune(doc any, resource string, fields map[string]bool, include includeTree) {
+	switch node := doc.(type) {
+	case []any:
+		for _, item := range node {
+			prune(item, resource, fields, include)
+		}
+	case map[string]any:
+		associations := resourceSchemas[resource].associations
+		for key, value := range node {
+			assoc, isAssoc := associations[key]
+			switch {
+			case isAssoc && include[key] != nil:
+				prune(value, assoc.resource, nil, include[key])
+			case isAssoc, fields != nil && !fields[key]:
+				delete(node, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/health"
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler struct {
+	ready *health.Checker
+}
+
+func NewHealthHandler(ready *health.Checker) *HealthHandler {
+	return &HealthHandler{ready: ready}
+}
+
+// Live godoc
+// @Summary Liveness probe
+// @Description Report that the process is up, without checking dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /healthz [get]
+func (hh HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, health.Report{Status: health.StatusOK})
+}
+
+// Ready godoc
+// @Summary Readiness probe
+// @Description Check the database, the schema version and the connection pool. Fails while the server is shutting down
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report "A check failed"
+// @Router /readyz [get]
+func (hh HealthHandler) Ready(c echo.Context) error {
+	report := hh.ready.Run(c.Request().Context())
+	if report.Status != health.StatusOK {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
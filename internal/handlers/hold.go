@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+type HoldHandler struct {
+	repository *repository.HoldRepository
+}
+
+func NewHoldHandler(r *repository.HoldRepository) *HoldHandler {
+	return &HoldHandler{repository: r}
+}
+
+// PlaceHold godoc
+// @Summary Place a hold on a book
+// @Description Queue a member for a book whose copies are all out. The next returned copy goes to the first eligible hold.
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param hold body models.NewHold true "Member"
+// @Success 201 {object} models.Hold
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found"
+// @Failure 409 {object} problem.Problem "A copy is available, no copy can come back, the member is inactive or already holds the book"
+// @Failure 422 {object} problem.Problem "Member doesn't exist"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/holds [post]
+func (hh HoldHandler) PlaceHold(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "HoldHandler.PlaceHold")
+	defer span.End()
+
+	bookID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	var newHold models.NewHold
+	err = c.Bind(&newHold)
+	if err != nil {
+		return invalidBody()
+	}
+
+	if errs := fieldErrors(&newHold); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	hold, err := hh.repository.Place(ctx, bookID, newHold.MemberID)
+	if err != nil {
+		return holdError(err, bookID, &newHold, 0)
+	}
+
+	return c.JSON(http.StatusCreated, hold)
+}
+
+// ListBookHolds godoc
+// @Summary Get the hold queue of a book
+// @Description Get the waiting and ready holds on a book, first placed first
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param status query string false "Status: waiting or ready"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Hold
+// @Header 200 {integer} X-Total-Count "Total number of holds"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid ID format, pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/holds [get]
+func (hh HoldHandler) ListBookHolds(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "HoldHandler.ListBookHolds")
+	defer span.End()
+
+	bookID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	holds, page, err := hh.repository.ReadByBook(ctx, bookID, q)
+	if err != nil {
+		return holdError(err, bookID, nil, 0)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, holds)
+}
+
+// ListMemberHolds godoc
+// @Summary Get the holds of a member
+// @Description Get every hold of a member with the queue position of the waiting ones
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param status query string false "Status: waiting, ready, fulfilled, cancelled or expired"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Hold
+// @Header 200 {integer} X-Total-Count "Total number of holds"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid ID format, pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id}/holds [get]
+func (hh HoldHandler) ListMemberHolds(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "HoldHandler.ListMemberHolds")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	holds, page, err := hh.repository.ReadByMember(ctx, id, q)
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, holds)
+}
+
+// GetHold godoc
+// @Summary Get hold by ID
+// @Description Get a hold with its queue position while it's waiting
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} models.Hold
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Hold not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /holds/{id} [get]
+func (hh HoldHandler) GetHold(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "HoldHandler.GetHold")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	hold, err := hh.repository.Read(ctx, id)
+	if err != nil {
+		return repositoryError(err, holdNotFound(id))
+	}
+
+	return c.JSON(http.StatusOK, hold)
+}
+
+// CancelHold godoc
+// @Summary Cancel a hold
+// @Description Take a hold out of the queue, the copy of a ready hold goes to the next hold in line
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} models.Hold
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Hold not found"
+// @Failure 409 {object} problem.Problem "Hold was already fulfilled, cancelled or expired"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /holds/{id}/cancel [post]
+func (hh HoldHandler) CancelHold(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "HoldHandler.CancelHold")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	hold, err := hh.repository.Cancel(ctx, id)
+	if err != nil {
+		return holdError(err, 0, nil, id)
+	}
+
+	return c.JSON(http.StatusOK, hold)
+}
+
+// holdError maps the refusals of placing or cancelling a hold to
+// problems with their own codes.
+func holdError(err error, bookID uint, newHold *models.NewHold, id uint) error {
+	switch {
+	case errors.Is(err, repository.ErrBookNotFound):
+		return bookNotFound(bookID)
+	case errors.Is(err, repository.ErrMemberNotFound) && newHold != nil:
+		return validationFailed([]problem.FieldError{{
+			Field: "member_id", Message: fmt.Sprintf("member %d doesn't exist", newHold.MemberID),
+		}})
+	case errors.Is(err, repository.ErrMemberInactive):
+		return problem.New(http.StatusConflict, problem.CodeMemberInactive,
+			"The member is suspended or their membership has expired.")
+	case errors.Is(err, repository.ErrCopiesAvailable):
+		return problem.New(http.StatusConflict, problem.CodeCopiesAvailable,
+			"A copy of the book is available, it can be checked out instead.")
+	case errors.Is(err, repository.ErrNoCopiesToHold):
+		return problem.New(http.StatusConflict, problem.CodeNoCopies, "The book has no copy that could come back.")
+	case errors.Is(err, repository.ErrHoldExists):
+		return problem.New(http.StatusConflict, problem.CodeHoldExists, "The member already holds the book.")
+	case errors.Is(err, repository.ErrHoldClosed):
+		return problem.New(http.StatusConflict, problem.CodeHoldClosed,
+			"The hold was already fulfilled, cancelled or expired.")
+	default:
+		return repositoryError(err, holdNotFound(id))
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const headerTotalCount = "X-Total-Count"
+
+// listParams are the query parameters handled by the listing itself,
+// every other parameter is passed to the repository as a filter.
+var listParams = map[string]bool{
+	"limit":   true,
+	"page":    true,
+	"cursor":  true,
+	"sort":    true,
+	"fields":  true,
+	"include": true,
+}
+
+func parseLimit(c echo.Context) (limit int, err error) {
+	s := c.QueryParam("limit")
+	if s == "" {
+		return repository.DefaultLimit, nil
+	}
+
+	limit, err = strconv.Atoi(s)
+	if err != nil || limit < 1 || limit > repository.MaxLimit {
+		return 0, invalidQuery("limit", fmt.Sprintf("expected an integer between 1 and %d", repository.MaxLimit))
+	}
+
+	return limit, nil
+}
+
+func parsePage(c echo.Context) (page int, err error) {
+	s := c.QueryParam("page")
+	if s == "" {
+		return 0, nil
+	}
+
+	page, err = strconv.Atoi(s)
+	if err != nil || page < 1 {
+		return 0, invalidQuery("page", "expected a positive integer")
+	}
+
+	return page, nil
+}
+
+func parseListQuery(c echo.Context) (q repository.ListQuery, err error) {
+	if q.Limit, err = parseLimit(c); err != nil {
+		return q, err
+	}
+	if q.Page, err = parsePage(c); err != nil {
+		return q, err
+	}
+
+	cursor := c.QueryParam("cursor")
+	switch {
+	case q.Page != 0 && cursor != "":
+		return q, invalidQuery("cursor", "can't be combined with page")
+	case cursor != "":
+		q.Cursor, err = repository.DecodeCursor(cursor)
+		if err != nil {
+			return q, invalidQuery("cursor", "malformed cursor")
+		}
+	}
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		q.Sort = strings.Split(sort, ",")
+		if q.Keyset() && q.Cursor == nil {
+			q.Page = 1
+		}
+	}
+
+	for param, values := range c.QueryParams() {
+		if listParams[param] {
+			continue
+		}
+		if q.Filters == nil {
+			q.Filters = make(map[string]string)
+		}
+		q.Filters[param] = values[0]
+	}
+
+	return q, nil
+}
+
+func setPageHeaders(c echo.Context, q repository.ListQuery, page repository.Page) {
+	header := c.Response().Header()
+	header.Set(headerTotalCount, strconv.FormatInt(page.Total, 10))
+
+	var links []string
+	if q.Keyset() {
+		if page.Next != nil {
+			links = append(links, pageLink(c, "next", "cursor", page.Next.Encode()))
+		}
+		if page.Prev != nil {
+			links = append(links, pageLink(c, "prev", "cursor", page.Prev.Encode()))
+		}
+	} else {
+		if int64(q.Page)*int64(q.Limit) < page.Total {
+			links = append(links, pageLink(c, "next", "page", strconv.Itoa(q.Page+1)))
+		}
+		if q.Page > 1 {
+			links = append(links, pageLink(c, "prev", "page", strconv.Itoa(q.Page-1)))
+		}
+	}
+
+	if len(links) > 0 {
+		header.Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageLink(c echo.Context, rel, param, value string) string {
+	u := *c.Request().URL
+	query := u.Query()
+	query.Del("page")
+	query.Del("cursor")
+	query.Set(param, value)
+	u.RawQuery = query.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type LoanHandler struct {
+	repository *repository.LoanRepository
+}
+
+func NewLoanHandler(r *repository.LoanRepository) *LoanHandler {
+	return &LoanHandler{repository: r}
+}
+
+// Checkout godoc
+// @Summary Check out a copy
+// @Description Lend a copy to a member, the due date follows the loan policy. A copy on hold can only be lent to the member it's held for, the member's hold on the book is fulfilled.
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param checkout body models.Checkout true "Copy and member"
+// @Success 201 {object} models.Loan
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 409 {object} problem.Problem "Copy unavailable or held for another member, member inactive or loan limit reached"
+// @Failure 422 {object} problem.Problem "Copy or member doesn't exist"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /loans [post]
+func (lh LoanHandler) Checkout(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.Checkout")
+	defer span.End()
+
+	var checkout models.Checkout
+	err := c.Bind(&checkout)
+	if err != nil {
+		return invalidBody()
+	}
+
+	if errs := fieldErrors(&checkout); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	loan, err := lh.repository.Checkout(ctx, &checkout)
+	if err != nil {
+		return loanError(err, &checkout, 0)
+	}
+
+	return c.JSON(http.StatusCreated, loan)
+}
+
+// GetLoan godoc
+// @Summary Get loan by ID
+// @Description Get a loan, open or returned
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Loan ID"
+// @Success 200 {object} models.Loan
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Loan not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /loans/{id} [get]
+func (lh LoanHandler) GetLoan(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.GetLoan")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lh.repository.Read(ctx, id)
+	if err != nil {
+		return repositoryError(err, loanNotFound(id))
+	}
+
+	return c.JSON(http.StatusOK, loan)
+}
+
+// ReturnLoan godoc
+// @Summary Return a copy
+// @Description Close the loan. The copy is assigned to the first eligible hold on the book, returned as assigned_hold, or made available again.
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Loan ID"
+// @Success 200 {object} models.Loan
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Loan not found"
+// @Failure 409 {object} problem.Problem "Loan was already returned"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /loans/{id}/return [post]
+func (lh LoanHandler) ReturnLoan(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.ReturnLoan")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lh.repository.Return(ctx, id)
+	if err != nil {
+		return loanError(err, nil, id)
+	}
+
+	return c.JSON(http.StatusOK, loan)
+}
+
+// RenewLoan godoc
+// @Summary Renew a loan
+// @Description Extend an open loan by the loan period from now. Refusals carry the reason as the problem code: member_inactive, loan_overdue, renewal_limit_reached or hold_pending.
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Loan ID"
+// @Success 200 {object} models.Loan
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Loan not found"
+// @Failure 409 {object} problem.Problem "Loan returned or can't be renewed"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /loans/{id}/renew [post]
+func (lh LoanHandler) RenewLoan(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.RenewLoan")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lh.repository.Renew(ctx, id)
+	if err != nil {
+		return loanError(err, nil, id)
+	}
+
+	return c.JSON(http.StatusOK, loan)
+}
+
+// ListMemberLoans godoc
+// @Summary Get the loans of a member
+// @Description Get the lending history of a member, open loans included
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param returned query bool false "Only returned (true) or open (false) loans"
+// @Param due_after query string false "Due after (RFC 3339 or date)"
+// @Param due_before query string false "Due before (RFC 3339 or date)"
+// @Param loaned_after query string false "Loaned after (RFC 3339 or date)"
+// @Param loaned_before query string false "Loaned before (RFC 3339 or date)"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Loan
+// @Header 200 {integer} X-Total-Count "Total number of loans"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid ID format, pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id}/loans [get]
+func (lh LoanHandler) ListMemberLoans(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.ListMemberLoans")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	loans, page, err := lh.repository.ReadByMember(ctx, id, q)
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, loans)
+}
+
+// ListCopyLoans godoc
+// @Summary Get the loans of a copy
+// @Description Get the lending history of a copy, the open loan included
+// @Tags loans
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param copy_id path int true "Copy ID"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param returned query bool false "Only returned (true) or open (false) loans"
+// @Param due_after query string false "Due after (RFC 3339 or date)"
+// @Param due_before query string false "Due before (RFC 3339 or date)"
+// @Param loaned_after query string false "Loaned after (RFC 3339 or date)"
+// @Param loaned_before query string false "Loaned before (RFC 3339 or date)"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Loan
+// @Header 200 {integer} X-Total-Count "Total number of loans"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid ID format, pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Book or copy not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /books/{id}/copies/{copy_id}/loans [get]
+func (lh LoanHandler) ListCopyLoans(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "LoanHandler.ListCopyLoans")
+	defer span.End()
+
+	bookID, id, err := parseCopyIDs(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	loans, page, err := lh.repository.ReadByCopy(ctx, bookID, id, q)
+	if err != nil {
+		return copyError(err, bookID, id)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, loans)
+}
+
+// loanError maps the refusals of a checkout, renewal or return to
+// problems with their own codes, so the desk or the self-service app
+// can tell why.
+// Records named in checkout that don't exist fail its validation.
+func loanError(err error, checkout *models.Checkout, id uint) error {
+	switch {
+	case errors.Is(err, repository.ErrCopyNotFound) && checkout != nil:
+		return validationFailed([]problem.FieldError{{
+			Field: "copy_id", Message: fmt.Sprintf("copy %d doesn't exist", checkout.CopyID),
+		}})
+	case errors.Is(err, repository.ErrMemberNotFound) && checkout != nil:
+		return validationFailed([]problem.FieldError{{
+			Field: "member_id", Message: fmt.Sprintf("member %d doesn't exist", checkout.MemberID),
+		}})
+	case errors.Is(err, repository.ErrCopyUnavailable):
+		return problem.New(http.StatusConflict, problem.CodeCopyUnavailable, "The copy isn't available for loan.")
+	case errors.Is(err, repository.ErrCopyOnHold):
+		return problem.New(http.StatusConflict, problem.CodeCopyOnHold, "The copy is held for another member.")
+	case errors.Is(err, repository.ErrMemberInactive):
+		return problem.New(http.StatusConflict, problem.CodeMemberInactive,
+			"The member is suspended or their membership has expired.")
+	case errors.Is(err, repository.ErrLoanLimitReached):
+		return problem.New(http.StatusConflict, problem.CodeLoanLimitReached,
+			"The member already has as many copies on loan as allowed.")
+	case errors.Is(err, repository.ErrLoanOverdue):
+		return problem.New(http.StatusConflict, problem.CodeLoanOverdue,
+			"The loan is overdue past the grace period, the copy must be returned.")
+	case errors.Is(err, repository.ErrRenewalLimitReached):
+		return problem.New(http.StatusConflict, problem.CodeRenewalLimitReached,
+			"The loan was already renewed as often as allowed.")
+	case errors.Is(err, repository.ErrHoldPending):
+		return problem.New(http.StatusConflict, problem.CodeHoldPending, "Another member is waiting for the book.")
+	case errors.Is(err, repository.ErrLoanReturned):
+		return problem.New(http.StatusConflict, problem.CodeLoanReturned, "The loan was already returned.")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return loanNotFound(id)
+	default:
+		return repositoryError(err, nil)
+	}
+}
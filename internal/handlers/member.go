@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+type MemberHandler struct {
+	repository *repository.MemberRepository
+}
+
+func NewMemberHandler(r *repository.MemberRepository) *MemberHandler {
+	return &MemberHandler{repository: r}
+}
+
+// ListMembers godoc
+// @Summary Get all members
+// @Description Get all members, q finds a member by card number or name
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param q query string false "Exact card number or name substring"
+// @Param card_number query string false "Exact card number"
+// @Param name query string false "Exact name"
+// @Param name~ query string false "Name substring, case-insensitive"
+// @Param type query string false "Member type: adult, child, student or staff"
+// @Param status query string false "Status: active, suspended or expired"
+// @Param expires_after query string false "Membership expires after (RFC 3339 or date)"
+// @Param expires_before query string false "Membership expires before (RFC 3339 or date)"
+// @Param created_after query string false "Created after (RFC 3339 or date)"
+// @Param created_before query string false "Created before (RFC 3339 or date)"
+// @Param updated_after query string false "Updated after (RFC 3339 or date)"
+// @Param updated_before query string false "Updated before (RFC 3339 or date)"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.Member
+// @Header 200 {integer} X-Total-Count "Total number of members"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members [get]
+func (mh MemberHandler) ListMembers(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.ListMembers")
+	defer span.End()
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	members, page, err := mh.repository.ReadAll(ctx, q)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, members)
+}
+
+// GetMember godoc
+// @Summary Get member by ID
+// @Description Get detailed information about a specific member
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Success 200 {object} models.Member
+// @Header 200 {string} ETag "Current version of the member"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id} [get]
+func (mh MemberHandler) GetMember(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.GetMember")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	member, err := mh.repository.Read(ctx, id)
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	setETag(c, member.Version)
+	return c.JSON(http.StatusOK, member)
+}
+
+// CreateMember godoc
+// @Summary Register a new member
+// @Description Add a new member, type and status default to adult and active
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param member body models.Member true "Member data"
+// @Success 201 {object} models.Member
+// @Header 201 {string} ETag "Current version of the member"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 409 {object} problem.Problem "Card number is already taken"
+// @Failure 422 {object} problem.Problem "Member data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members [post]
+func (mh MemberHandler) CreateMember(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.CreateMember")
+	defer span.End()
+
+	var member models.Member
+	err := c.Bind(&member)
+	if err != nil {
+		return invalidBody()
+	}
+
+	err = validateMember(&member)
+	if err != nil {
+		return err
+	}
+
+	err = mh.repository.Create(ctx, &member)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	setETag(c, member.Version)
+	return c.JSON(http.StatusCreated, member)
+}
+
+// UpdateMember godoc
+// @Summary Replace member information
+// @Description Replace existing member's data, omitted fields are reset
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param member body models.Member true "Updated member data"
+// @Success 204 "No content"
+// @Header 204 {string} ETag "New version of the member"
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found by entered id"
+// @Failure 409 {object} problem.Problem "Card number is already taken"
+// @Failure 412 {object} problem.Problem "Member was modified since the If-Match version"
+// @Failure 422 {object} problem.Problem "Member data is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id} [put]
+func (mh MemberHandler) UpdateMember(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.UpdateMember")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var member models.Member
+	err = c.Bind(&member)
+	if err != nil {
+		return invalidBody()
+	}
+
+	err = validateMember(&member)
+	if err != nil {
+		return err
+	}
+
+	err = mh.repository.Update(ctx, id, version, &member)
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	setETag(c, member.Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PatchMember godoc
+// @Summary Partially update member information
+// @Description Apply a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to the member, e.g. to suspend them or extend the membership
+// @Tags members
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "Merge patch object or JSON patch operations"
+// @Success 200 {object} models.Member
+// @Header 200 {string} ETag "New version of the member"
+// @Failure 400 {object} problem.Problem "Invalid ID format or patch document"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found by entered id"
+// @Failure 409 {object} problem.Problem "Patch test operation failed or card number is already taken"
+// @Failure 412 {object} problem.Problem "Member was modified since the If-Match version"
+// @Failure 415 {object} problem.Problem "Unsupported patch format"
+// @Failure 422 {object} problem.Problem "Patch can't be applied or the patched member is invalid"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id} [patch]
+func (mh MemberHandler) PatchMember(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.PatchMember")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	patch, err := newPatcher(c)
+	if err != nil {
+		return err
+	}
+
+	member, err := mh.repository.Patch(ctx, id, version, func(member *models.Member) error {
+		if err := applyPatch(patch, member); err != nil {
+			return err
+		}
+		return validateMember(member)
+	})
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	setETag(c, member.Version)
+	return c.JSON(http.StatusOK, member)
+}
+
+// DeleteMember godoc
+// @Summary Delete a member
+// @Description Remove a member
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param id path int true "Member ID"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 204 "No content"
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "Member not found by entered id"
+// @Failure 412 {object} problem.Problem "Member was modified since the If-Match version"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /members/{id} [delete]
+func (mh MemberHandler) DeleteMember(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "MemberHandler.DeleteMember")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	err = mh.repository.Delete(ctx, id, version)
+	if err != nil {
+		return repositoryError(err, memberNotFound(id))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// validateMember fills in the default type and status and checks
+// member against the model rules. The member's own ID isn't validated,
+// it comes from the path or is assigned on create.
+func validateMember(member *models.Member) error {
+	if member.Type == "" {
+		member.Type = models.MemberAdult
+	}
+	if member.Status == "" {
+		member.Status = models.MemberActive
+	}
+
+	v := *member
+	v.ID = 0
+	if errs := fieldErrors(&v); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+	return nil
+}
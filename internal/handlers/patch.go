@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/problem"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+// patcher transforms the JSON representation of a resource.
+type patcher func(doc []byte) ([]byte, error)
+
+// patchError is returned when a well-formed patch can't be applied to
+// the current state of a resource.
+type patchError struct {
+	err error
+}
+
+func (e *patchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *patchError) problem() *problem.Problem {
+	if errors.Is(e.err, jsonpatch.ErrTestFailed) {
+		return problem.New(http.StatusConflict, problem.CodePatchTestFailed, "Patch test operation failed.")
+	}
+	return problem.Newf(http.StatusUnprocessableEntity, problem.CodePatchFailed, "Patch can't be applied: %s.", e.err)
+}
+
+// newPatcher reads the request body as a JSON Merge Patch (RFC 7396)
+// or a JSON Patch (RFC 6902), depending on the content type.
+func newPatcher(c echo.Context) (patcher, error) {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, invalidBody()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case MIMEMergePatch:
+		if !json.Valid(body) {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "Invalid merge patch.")
+		}
+		return func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}, nil
+	case MIMEJSONPatch:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "Invalid JSON patch.")
+		}
+		return patch.Apply, nil
+	default:
+		return nil, problem.Newf(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"Expected %s or %s body.", MIMEMergePatch, MIMEJSONPatch)
+	}
+}
+
+// applyPatch patches the JSON representation of v and replaces v with
+// the decoded result, so fields the patch removed end up zeroed.
+func applyPatch[T any](patch patcher, v *T) error {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if doc, err = patch(doc); err != nil {
+		return &patchError{err: err}
+	}
+
+	var patched T
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return &patchError{err: err}
+	}
+
+	*v = patched
+	return nil
+}
@@ -1,31 +1,116 @@
 package handlers
 
 import (
+	"net/http"
+	"time"
+
 	_ "github.com/4otis/library_api_2025/docs"
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
 	"github.com/4otis/library_api_2025/internal/repository"
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(e *echo.Echo, db *gorm.DB) {
+// SetupRoutes registers the API. Apart from logging in and the docs,
+// every route requires an access token issued by tokens or an API key,
+// and the permissions declared next to it. Refresh tokens stay valid for
+// refreshTTL since their last use, loans follow policy and requests
+// are bounded by limits.
+func SetupRoutes(e *echo.Echo, db *gorm.DB, tokens *auth.Tokens, refreshTTL time.Duration, policy config.Circulation, limits config.API) {
+	e.HTTPErrorHandler = ErrorHandler
+
 	bookRepo := repository.NewBookRepository(db)
 	authorRepo := repository.NewAuthorRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	memberRepo := repository.NewMemberRepository(db)
+	copyRepo := repository.NewCopyRepository(db, policy)
+	loanRepo := repository.NewLoanRepository(db, policy)
+	holdRepo := repository.NewHoldRepository(db, policy)
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
 
-	bookHandler := NewBookHandler(bookRepo)
-	authorHandler := NewAuthorHandler(authorRepo)
-
-	e.GET("/books", bookHandler.ListBooks)
-	e.GET("/books/:id", bookHandler.GetBook)
-	e.POST("/books", bookHandler.CreateBook)
-	e.PUT("/books/:id", bookHandler.UpdateBook)
-	e.DELETE("/books/:id", bookHandler.DeleteBook)
+	bookHandler := NewBookHandler(bookRepo, limits.MaxIncludeDepth)
+	authorHandler := NewAuthorHandler(authorRepo, limits.MaxIncludeDepth)
+	searchHandler := NewSearchHandler(searchRepo)
+	memberHandler := NewMemberHandler(memberRepo)
+	copyHandler := NewCopyHandler(copyRepo)
+	loanHandler := NewLoanHandler(loanRepo)
+	holdHandler := NewHoldHandler(holdRepo)
+	authHandler := NewAuthHandler(userRepo, sessionRepo, tokens, refreshTTL)
+	userHandler := NewUserHandler(userRepo)
+	apiKeyHandler := NewAPIKeyHandler(apiKeyRepo)
 
-	e.GET("/authors", authorHandler.ListAuthors)
-	e.GET("/authors/:id", authorHandler.GetAuthor)
-	e.POST("/authors", authorHandler.CreateAuthor)
-	e.PUT("/authors/:id", authorHandler.UpdateAuthor)
-	e.DELETE("/authors/:id", authorHandler.DeleteAuthor)
+	e.POST("/auth/login", authHandler.Login)
+	e.POST("/auth/refresh", authHandler.Refresh)
+	e.POST("/auth/logout", authHandler.Logout)
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
+
+	api := e.Group("", auth.Middleware(tokens, sessionRepo, userRepo, apiKeyRepo))
+
+	api.GET("/books", bookHandler.ListBooks, auth.Require(auth.BooksRead))
+	api.GET("/books/:id", bookHandler.GetBook, auth.Require(auth.BooksRead))
+	api.POST("/books", bookHandler.CreateBook, auth.Require(auth.BooksWrite))
+	api.PUT("/books/:id", bookHandler.UpdateBook, auth.Require(auth.BooksWrite))
+	api.PATCH("/books/:id", bookHandler.PatchBook, auth.Require(auth.BooksWrite))
+	api.DELETE("/books/:id", bookHandler.DeleteBook, auth.Require(auth.BooksDelete))
+
+	api.GET("/books/:id/copies", copyHandler.ListCopies, auth.Require(auth.BooksRead))
+	api.GET("/books/:id/copies/:copy_id", copyHandler.GetCopy, auth.Require(auth.BooksRead))
+	api.POST("/books/:id/copies", copyHandler.CreateCopy, auth.Require(auth.CopiesWrite))
+	api.PUT("/books/:id/copies/:copy_id", copyHandler.UpdateCopy, auth.Require(auth.CopiesWrite))
+	api.DELETE("/books/:id/copies/:copy_id", copyHandler.DeleteCopy, auth.Require(auth.CopiesDelete))
+	api.GET("/books/:id/copies/:copy_id/loans", loanHandler.ListCopyLoans, auth.Require(auth.LoansRead))
+	api.GET("/books/:id/holds", holdHandler.ListBookHolds, auth.Require(auth.HoldsRead))
+	api.POST("/books/:id/holds", holdHandler.PlaceHold, auth.Require(auth.HoldsWrite))
+
+	api.GET("/authors", authorHandler.ListAuthors, auth.Require(auth.AuthorsRead))
+	api.GET("/authors/:id", authorHandler.GetAuthor, auth.Require(auth.AuthorsRead))
+	api.POST("/authors", authorHandler.CreateAuthor, auth.Require(auth.AuthorsWrite))
+	api.PUT("/authors/:id", authorHandler.UpdateAuthor, auth.Require(auth.AuthorsWrite))
+	api.PATCH("/authors/:id", authorHandler.PatchAuthor, auth.Require(auth.AuthorsWrite))
+	api.DELETE("/authors/:id", authorHandler.DeleteAuthor, auth.Require(auth.AuthorsDelete))
+
+	api.GET("/search", searchHandler.Search, auth.Require(auth.BooksRead, auth.AuthorsRead))
+
+	api.GET("/members", memberHandler.ListMembers, auth.Require(auth.MembersRead))
+	api.GET("/members/:id", memberHandler.GetMember, auth.Require(auth.MembersRead))
+	api.POST("/members", memberHandler.CreateMember, auth.Require(auth.MembersWrite))
+	api.PUT("/members/:id", memberHandler.UpdateMember, auth.Require(auth.MembersWrite))
+	api.PATCH("/members/:id", memberHandler.PatchMember, auth.Require(auth.MembersWrite))
+	api.DELETE("/members/:id", memberHandler.DeleteMember, auth.Require(auth.MembersDelete))
+	api.GET("/members/:id/loans", loanHandler.ListMemberLoans, auth.Require(auth.MembersRead, auth.LoansRead))
+	api.GET("/members/:id/holds", holdHandler.ListMemberHolds, auth.Require(auth.MembersRead, auth.HoldsRead))
+
+	api.POST("/loans", loanHandler.Checkout, auth.Require(auth.LoansWrite))
+	api.GET("/loans/:id", loanHandler.GetLoan, auth.Require(auth.LoansRead))
+	api.POST("/loans/:id/return", loanHandler.ReturnLoan, auth.Require(auth.LoansWrite))
+	api.POST("/loans/:id/renew", loanHandler.RenewLoan, auth.Require(auth.LoansWrite))
+
+	api.GET("/holds/:id", holdHandler.GetHold, auth.Require(auth.HoldsRead))
+	api.POST("/holds/:id/cancel", holdHandler.CancelHold, auth.Require(auth.HoldsWrite))
+
+	api.GET("/users", userHandler.ListUsers, auth.Require(auth.UsersManage))
+	api.GET("/users/:id", userHandler.GetUser, auth.Require(auth.UsersManage))
+	api.PUT("/users/:id/roles", userHandler.SetUserRoles, auth.Require(auth.UsersManage))
+
+	api.GET("/api-keys", apiKeyHandler.ListAPIKeys, auth.Require(auth.APIKeysManage))
+	api.GET("/api-keys/:id", apiKeyHandler.GetAPIKey, auth.Require(auth.APIKeysManage))
+	api.POST("/api-keys", apiKeyHandler.CreateAPIKey, auth.Require(auth.APIKeysManage))
+	api.POST("/api-keys/:id/rotate", apiKeyHandler.RotateAPIKey, auth.Require(auth.APIKeysManage))
+	api.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey, auth.Require(auth.APIKeysManage))
+}
+
+// SetupHealthRoutes registers the liveness and readiness probes.
+func SetupHealthRoutes(e *echo.Echo, h *HealthHandler) {
+	e.GET("/healthz", h.Live)
+	e.GET("/readyz", h.Ready)
+}
+
+// SetupMetricsRoutes exposes the Prometheus metrics handler.
+func SetupMetricsRoutes(e *echo.Echo, metrics http.Handler) {
+	e.GET("/metrics", echo.WrapHandler(metrics))
 }
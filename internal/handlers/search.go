@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+type SearchHandler struct {
+	repository *repository.SearchRepository
+}
+
+func NewSearchHandler(r *repository.SearchRepository) *SearchHandler {
+	return &SearchHandler{repository: r}
+}
+
+// Search godoc
+// @Summary Full-text search
+// @Description Ranked full-text search over book titles and author names
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query (websearch syntax: quotes, or, -)"
+// @Param type query string false "Comma-separated result types: book, author"
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number"
+// @Success 200 {array} models.SearchResult
+// @Header 200 {integer} X-Total-Count "Total number of results"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Missing query or invalid parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /search [get]
+func (sh SearchHandler) Search(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "SearchHandler.Search")
+	defer span.End()
+
+	text := strings.TrimSpace(c.QueryParam("q"))
+	if text == "" {
+		return invalidQuery("q", "missing search query")
+	}
+
+	var q repository.ListQuery
+	var err error
+	if q.Limit, err = parseLimit(c); err != nil {
+		return err
+	}
+	if q.Page, err = parsePage(c); err != nil {
+		return err
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+
+	var types []string
+	if t := c.QueryParam("type"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	results, page, err := sh.repository.Search(ctx, text, types, q)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, results)
+}
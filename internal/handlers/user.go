@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+)
+
+type UserHandler struct {
+	repository *repository.UserRepository
+}
+
+func NewUserHandler(r *repository.UserRepository) *UserHandler {
+	return &UserHandler{repository: r}
+}
+
+// ListUsers godoc
+// @Summary Get all users
+// @Description Get all users with their roles
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param limit query int false "Page size (1..100, default 20)"
+// @Param page query int false "Page number, switches to offset pagination"
+// @Param cursor query string false "Opaque keyset cursor taken from the Link header"
+// @Param username query string false "Exact username"
+// @Param username~ query string false "Username substring, case-insensitive"
+// @Param role query string false "Only users with this role"
+// @Param sort query string false "Comma-separated sort keys, \"-\" prefix for descending"
+// @Success 200 {array} models.User
+// @Header 200 {integer} X-Total-Count "Total number of users"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid pagination, filter or sort parameters"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /users [get]
+func (uh UserHandler) ListUsers(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "UserHandler.ListUsers")
+	defer span.End()
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	users, page, err := uh.repository.ReadAll(ctx, q)
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	setPageHeaders(c, q, page)
+	return c.JSON(http.StatusOK, users)
+}
+
+// GetUser godoc
+// @Summary Get user by ID
+// @Description Get a user with their roles
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem "Invalid ID format"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /users/{id} [get]
+func (uh UserHandler) GetUser(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "UserHandler.GetUser")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := uh.repository.Read(ctx, id)
+	if err != nil {
+		return repositoryError(err, userNotFound(id))
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// SetUserRoles godoc
+// @Summary Assign roles
+// @Description Replace the roles of a user. The change applies to the user's next request.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param roles body models.RoleAssignment true "Roles: reader, librarian, cataloguer or admin"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem "Invalid ID format or request body"
+// @Failure 401 {object} problem.Problem "Missing or invalid access token"
+// @Failure 403 {object} problem.Problem "Missing permission"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "The last admin can't lose the admin role"
+// @Failure 422 {object} problem.Problem "Unknown or duplicated roles"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /users/{id}/roles [put]
+func (uh UserHandler) SetUserRoles(c echo.Context) error {
+	ctx, span := tracing.Start(c.Request().Context(), "UserHandler.SetUserRoles")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	var assignment models.RoleAssignment
+	if err := c.Bind(&assignment); err != nil {
+		return invalidBody()
+	}
+
+	if errs := fieldErrors(&assignment); len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	user, err := uh.repository.SetRoles(ctx, id, assignment.Roles, string(auth.RoleAdmin))
+	if errors.Is(err, repository.ErrLastRoleHolder) {
+		return problem.New(http.StatusConflict, problem.CodeLastAdmin, "The last admin can't lose the admin role.")
+	}
+	if err != nil {
+		return repositoryError(err, userNotFound(id))
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks the `validate` rules declared on the models. Field
+// paths use JSON names, so errors point at the request body.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// fieldErrors returns every rule v violates. Nested entries with an ID
+// refer to existing records, so only new ones must be complete.
+func fieldErrors(v any) []problem.FieldError {
+	err := validate.Struct(v)
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+
+	errs := make([]problem.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		errs = append(errs, problem.FieldError{Field: field, Message: ruleMessage(fe)})
+	}
+	return errs
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "email":
+		return "must be an email address"
+	case "unique":
+		return "must not contain duplicates"
+	default:
+		return fmt.Sprintf("must satisfy %s", fe.Tag())
+	}
+}
+
+func validationFailed(errs []problem.FieldError) *problem.Problem {
+	return problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed, "Request body is invalid.").
+		WithErrors(errs...)
+}
+
+// requireNested makes creating records nested in another resource need
+// the permission of creating them directly, e.g. a key scoped to
+// books:write can't add authors along with a book.
+func requireNested(ctx context.Context, perm auth.Permission) error {
+	if p, ok := auth.PrincipalFrom(ctx); ok && !p.Can(perm) {
+		return problem.Newf(http.StatusForbidden, problem.CodeForbidden, "Missing permission %s.", perm)
+	}
+	return nil
+}
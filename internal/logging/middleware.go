@@ -0,0 +1,93 @@
+package logging
+
+import (
+	cryptorand "crypto/rand"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware propagates the X-Request-ID header, or generates
+// an ID when it's missing or malformed. The ID is echoed in the
+// response and carried in the request context.
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = cryptorand.Text()
+			}
+
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			c.SetRequest(c.Request().WithContext(WithRequestID(c.Request().Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// AccessLogMiddleware writes one line per request. Successful requests
+// are sampled at sampleRate, failed ones are always logged.
+func AccessLogMiddleware(logger *slog.Logger, sampleRate float64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			if res.Status < http.StatusBadRequest && rand.Float64() >= sampleRate {
+				return nil
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case res.Status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case res.Status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+
+			logger.LogAttrs(req.Context(), level, "request",
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", req.URL.Path),
+				slog.String("query", redactQuery(req.URL.Query())),
+				slog.Int("status", res.Status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes_in", req.ContentLength),
+				slog.Int64("bytes_out", res.Size),
+				slog.String("remote_ip", c.RealIP()),
+				slog.String("user_agent", req.UserAgent()),
+			)
+			return nil
+		}
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func redactQuery(query url.Values) string {
+	for key := range query {
+		if IsSensitive(key) {
+			query[key] = []string{redacted}
+		}
+	}
+	return query.Encode()
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var catalogQueries = map[string]string{
+	"books":   "select count(*) from books where deleted_at is null",
+	"authors": "select count(*) from authors where deleted_at is null",
+	"links":   "select count(*) from books_authors",
+}
+
+// CatalogWorker refreshes the catalog gauges every interval until ctx
+// is cancelled. Counting at scrape time would let every scraper run
+// full table counts.
+func (m *Metrics) CatalogWorker(db *gorm.DB, interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			m.refreshCatalog(ctx, db)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+func (m *Metrics) refreshCatalog(ctx context.Context, db *gorm.DB) {
+	for kind, query := range catalogQueries {
+		var n int64
+		if err := db.WithContext(ctx).Raw(query).Scan(&n).Error; err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error. Failed to count catalog items", "kind", kind, "error", err)
+			}
+			continue
+		}
+		m.catalog.WithLabelValues(kind).Set(float64(n))
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"runtime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	startKey = "metrics:start"
+
+	repositoryPackage = "github.com/4otis/library_api_2025/internal/repository."
+)
+
+// GormPlugin times every SQL statement and labels it with the
+// repository method found on the call stack, so repositories don't
+// need to be instrumented one by one.
+type GormPlugin struct {
+	m *Metrics
+}
+
+func (m *Metrics) GormPlugin() *GormPlugin {
+	return &GormPlugin{m: m}
+}
+
+func (p *GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", p.before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", p.before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", p.before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", p.before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", p.before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", p.after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormPlugin) before(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (p *GormPlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+
+	repository, method := repositoryMethod()
+	p.m.queries.WithLabelValues(repository, method).Observe(time.Since(v.(time.Time)).Seconds())
+}
+
+// repositoryMethod returns the innermost repository method on the call
+// stack, e.g. ("BookRepository", "ReadAll"). Helpers and closures are
+// skipped because they have no receiver.
+func repositoryMethod() (repository, method string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, repositoryPackage); ok {
+			receiver, method, ok := strings.Cut(name, ".")
+			receiver = strings.TrimSuffix(strings.TrimPrefix(receiver, "(*"), ")")
+			if ok && !strings.Contains(method, ".") && strings.HasSuffix(receiver, "Repository") {
+				return receiver, method
+			}
+		}
+		if !more {
+			return "other", "other"
+		}
+	}
+}
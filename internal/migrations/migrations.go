@@ -1,40 +1,135 @@
 package migrations
 
 import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
 	"gorm.io/gorm"
 )
 
-func RunInitMigrations(db *gorm.DB) error {
-	return db.Transaction(func(tx *gorm.DB) error {
-		return tx.Exec(
-			`
-			drop table if exists books_authors;
-			drop table if exists books;
-			drop table if exists authors;
-
-			create table books (
-			id serial primary key,
-			title varchar(64) not null,
-			pages integer not null,
-			created_at timestamp with time zone,
-			updated_at timestamp with time zone,
-			deleted_at timestamp with time zone
-			);
-
-			create table authors (
-			id serial primary key,
-			name varchar(64) not null,
-			created_at timestamp with time zone,
-			updated_at timestamp with time zone,
-			deleted_at timestamp with time zone
-			);
-
-			create table books_authors (
-			book_id integer not null,
-			author_id integer not null,
-			primary key (book_id, author_id),
-			constraint fk_book foreign key (book_id) references books(id) on delete cascade,
-			constraint fk_author foreign key (author_id) references authors(id) on delete cascade
-			);`).Error
+// The scripts of each database live in a directory named after its
+// gorm dialect.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified"
+	StateUnknown  = "unknown"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up and down scripts. Checksum is taken over
+// the up script, so an applied migration can't be edited unnoticed.
+type Migration struct {
+	Version  uint
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a migration known to the binary, the database or
+// both. AppliedAt is nil for pending migrations.
+type Status struct {
+	Version   uint
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Load reads migrations from the files in dir, named like
+// 0001_init.up.sql and 0001_init.down.sql, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 0)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[uint(version)]
+		if m == nil {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[m.Version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", m.Version)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(script)
+			sum := sha256.Sum256(script)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both up and down scripts", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return int(a.Version) - int(b.Version)
 	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration embedded in the binary.
+func Up(db *gorm.DB) error {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Up()
+	return err
 }
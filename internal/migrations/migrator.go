@@ -0,0 +1,227 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// schemaTables creates the schema_migrations table in each dialect.
+var schemaTables = map[string]string{
+	"postgres": `create table if not exists schema_migrations (
+		version bigint primary key,
+		name text not null,
+		checksum text not null,
+		applied_at timestamp with time zone not null
+	)`,
+	"sqlite": `create table if not exists schema_migrations (
+		version integer primary key,
+		name text not null,
+		checksum text not null,
+		applied_at datetime not null
+	)`,
+}
+
+// Migrator applies and rolls back migrations, recording them in the
+// schema_migrations table. On Postgres changes run under an advisory
+// lock, so instances starting together migrate one at a time. A SQLite
+// file is only used by one instance.
+type Migrator struct {
+	db         *gorm.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations written for the dialect of db.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	if _, ok := schemaTables[dialect]; !ok {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+
+	migrations, err := Load(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Latest returns the version the binary expects the database to have.
+func (m Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the latest applied version, 0 for an empty database.
+func (m Migrator) Version(ctx context.Context) (uint, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Up applies pending migrations in order, each in its own
+// transaction. It refuses to run if an applied migration was modified
+// or is unknown to the binary.
+func (m Migrator) Up() (done []Migration, err error) {
+	err = m.locked(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if slices.ContainsFunc(applied, func(a schemaMigration) bool { return a.Version == migration.Version }) {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					Checksum:  migration.Checksum,
+					AppliedAt: tx.NowFunc(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m Migrator) Down(steps int) (done []Migration, err error) {
+	err = m.locked(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+			migration, ok := m.find(applied[i].Version)
+			if !ok {
+				return fmt.Errorf("migration %d: %w", applied[i].Version, ErrUnknownMigration)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&applied[i]).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists the migrations known to the binary and the database,
+// ordered by version.
+func (m Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name, State: StatePending}
+		i := slices.IndexFunc(applied, func(a schemaMigration) bool { return a.Version == migration.Version })
+		if i >= 0 {
+			status.State = StateApplied
+			if applied[i].Checksum != migration.Checksum {
+				status.State = StateModified
+			}
+			status.AppliedAt = &applied[i].AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	for i := range applied {
+		if _, ok := m.find(applied[i].Version); !ok {
+			statuses = append(statuses, Status{
+				Version:   applied[i].Version,
+				Name:      applied[i].Name,
+				State:     StateUnknown,
+				AppliedAt: &applied[i].AppliedAt,
+			})
+		}
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return int(a.Version) - int(b.Version)
+	})
+
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration lock.
+// The lock is session-level, so it outlives the transactions of the
+// individual migrations.
+func (m Migrator) locked(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if m.dialect == "postgres" {
+			if err := conn.Exec("select pg_advisory_lock(hashtext('schema_migrations'))").Error; err != nil {
+				return err
+			}
+			defer conn.Exec("select pg_advisory_unlock(hashtext('schema_migrations'))")
+		}
+
+		if err := conn.Exec(schemaTables[m.dialect]).Error; err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+func (m Migrator) applied(db *gorm.DB) (applied []schemaMigration, err error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return nil, nil
+	}
+
+	err = db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+func (m Migrator) verify(applied []schemaMigration) error {
+	for _, a := range applied {
+		migration, ok := m.find(a.Version)
+		if !ok {
+			return fmt.Errorf("migration %d %s: %w", a.Version, a.Name, ErrUnknownMigration)
+		}
+		if migration.Checksum != a.Checksum {
+			return fmt.Errorf("migration %d %s: %w", a.Version, a.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+func (m Migrator) find(version uint) (Migration, bool) {
+	i := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+	if i < 0 {
+		return Migration{}, false
+	}
+	return m.migrations[i], true
+}
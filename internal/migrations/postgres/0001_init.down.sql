@@ -0,0 +1,3 @@
+drop table if exists books_authors;
+drop table if exists books;
+drop table if exists authors;
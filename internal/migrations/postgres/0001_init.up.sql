@@ -0,0 +1,36 @@
+-- "if not exists" lets databases created before versioned migrations adopt this baseline.
+
+create table if not exists books (
+id serial primary key,
+title varchar(64) not null,
+pages integer not null,
+version integer not null default 1,
+created_at timestamp with time zone,
+updated_at timestamp with time zone,
+deleted_at timestamp with time zone,
+search_vector tsvector generated always as (to_tsvector('simple', title)) stored
+);
+
+create index if not exists books_created_at_id_idx on books (created_at, id);
+create index if not exists books_search_vector_idx on books using gin (search_vector);
+
+create table if not exists authors (
+id serial primary key,
+name varchar(64) not null,
+version integer not null default 1,
+created_at timestamp with time zone,
+updated_at timestamp with time zone,
+deleted_at timestamp with time zone,
+search_vector tsvector generated always as (to_tsvector('simple', name)) stored
+);
+
+create index if not exists authors_created_at_id_idx on authors (created_at, id);
+create index if not exists authors_search_vector_idx on authors using gin (search_vector);
+
+create table if not exists books_authors (
+book_id integer not null,
+author_id integer not null,
+primary key (book_id, author_id),
+constraint fk_book foreign key (book_id) references books(id) on delete cascade,
+constraint fk_author foreign key (author_id) references authors(id) on delete cascade
+);
@@ -0,0 +1,8 @@
+create table user_roles (
+user_id integer not null,
+role varchar(32) not null,
+primary key (user_id, role),
+constraint fk_user foreign key (user_id) references users(id) on delete cascade
+);
+
+create index user_roles_role_idx on user_roles (role);
@@ -0,0 +1,2 @@
+drop table if exists api_key_scopes;
+drop table if exists api_keys;
@@ -0,0 +1,22 @@
+create table holds (
+id serial primary key,
+book_id integer not null,
+member_id integer not null,
+status varchar(16) not null,
+copy_id integer,
+ready_at timestamp with time zone,
+pickup_by timestamp with time zone,
+created_at timestamp with time zone,
+updated_at timestamp with time zone,
+deleted_at timestamp with time zone,
+constraint fk_book foreign key (book_id) references books(id),
+constraint fk_member foreign key (member_id) references members(id),
+constraint fk_copy foreign key (copy_id) references copies(id)
+);
+
+create unique index holds_active_book_id_member_id_idx on holds (book_id, member_id)
+where status in ('waiting', 'ready') and deleted_at is null;
+create index holds_book_id_status_idx on holds (book_id, status, created_at, id);
+create index holds_member_id_idx on holds (member_id);
+create index holds_pickup_by_idx on holds (pickup_by) where status = 'ready';
+create index holds_created_at_id_idx on holds (created_at, id);
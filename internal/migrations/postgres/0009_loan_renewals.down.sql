@@ -0,0 +1 @@
+alter table loans drop column renewals;
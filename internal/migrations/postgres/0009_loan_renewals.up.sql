@@ -0,0 +1 @@
+alter table loans add column renewals integer not null default 0;
@@ -0,0 +1 @@
+-- The columns and indexes belong to 0001_init, which drops them.
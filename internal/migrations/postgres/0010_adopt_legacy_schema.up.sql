@@ -0,0 +1,12 @@
+-- Databases created before versioned migrations have the tables of
+-- 0001_init without versions and search vectors.
+
+alter table books add column if not exists version integer not null default 1;
+alter table books add column if not exists search_vector tsvector generated always as (to_tsvector('simple', title)) stored;
+create index if not exists books_created_at_id_idx on books (created_at, id);
+create index if not exists books_search_vector_idx on books using gin (search_vector);
+
+alter table authors add column if not exists version integer not null default 1;
+alter table authors add column if not exists search_vector tsvector generated always as (to_tsvector('simple', name)) stored;
+create index if not exists authors_created_at_id_idx on authors (created_at, id);
+create index if not exists authors_search_vector_idx on authors using gin (search_vector);
@@ -0,0 +1,5 @@
+drop table if exists authors_search;
+drop table if exists books_search;
+drop table if exists books_authors;
+drop table if exists books;
+drop table if exists authors;
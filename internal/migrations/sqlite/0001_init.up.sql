@@ -0,0 +1,62 @@
+create table books (
+id integer primary key autoincrement,
+title varchar(64) not null,
+pages integer not null,
+version integer not null default 1,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime
+);
+
+create index books_created_at_id_idx on books (created_at, id);
+
+create table authors (
+id integer primary key autoincrement,
+name varchar(64) not null,
+version integer not null default 1,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime
+);
+
+create index authors_created_at_id_idx on authors (created_at, id);
+
+create table books_authors (
+book_id integer not null,
+author_id integer not null,
+primary key (book_id, author_id),
+constraint fk_book foreign key (book_id) references books(id) on delete cascade,
+constraint fk_author foreign key (author_id) references authors(id) on delete cascade
+);
+
+-- Full-text indexes over the titles and names, kept in sync by triggers.
+
+create virtual table books_search using fts5 (title, content='books', content_rowid='id');
+
+create trigger books_search_insert after insert on books begin
+insert into books_search (rowid, title) values (new.id, new.title);
+end;
+
+create trigger books_search_delete after delete on books begin
+insert into books_search (books_search, rowid, title) values ('delete', old.id, old.title);
+end;
+
+create trigger books_search_update after update of title on books begin
+insert into books_search (books_search, rowid, title) values ('delete', old.id, old.title);
+insert into books_search (rowid, title) values (new.id, new.title);
+end;
+
+create virtual table authors_search using fts5 (name, content='authors', content_rowid='id');
+
+create trigger authors_search_insert after insert on authors begin
+insert into authors_search (rowid, name) values (new.id, new.name);
+end;
+
+create trigger authors_search_delete after delete on authors begin
+insert into authors_search (authors_search, rowid, name) values ('delete', old.id, old.name);
+end;
+
+create trigger authors_search_update after update of name on authors begin
+insert into authors_search (authors_search, rowid, name) values ('delete', old.id, old.name);
+insert into authors_search (rowid, name) values (new.id, new.name);
+end;
@@ -0,0 +1,23 @@
+create table users (
+id integer primary key autoincrement,
+username varchar(64) not null,
+password_hash text not null,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime
+);
+
+create unique index users_username_idx on users (username) where deleted_at is null;
+
+create table sessions (
+id text primary key,
+user_id integer not null,
+refresh_hash text not null,
+expires_at datetime not null,
+revoked_at datetime,
+created_at datetime,
+updated_at datetime,
+constraint fk_user foreign key (user_id) references users(id) on delete cascade
+);
+
+create index sessions_user_id_idx on sessions (user_id);
@@ -0,0 +1,20 @@
+create table api_keys (
+id text primary key,
+name varchar(64) not null,
+hash text not null,
+created_by integer,
+expires_at datetime,
+revoked_at datetime,
+last_used_at datetime,
+replaced_by text,
+created_at datetime,
+updated_at datetime,
+constraint fk_created_by foreign key (created_by) references users(id) on delete set null
+);
+
+create table api_key_scopes (
+api_key_id text not null,
+scope varchar(32) not null,
+primary key (api_key_id, scope),
+constraint fk_api_key foreign key (api_key_id) references api_keys(id) on delete cascade
+);
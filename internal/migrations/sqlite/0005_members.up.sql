@@ -0,0 +1,18 @@
+create table members (
+id integer primary key autoincrement,
+card_number varchar(32) not null,
+name varchar(128) not null,
+email varchar(254) not null default '',
+phone varchar(32) not null default '',
+address varchar(256) not null default '',
+type varchar(16) not null,
+status varchar(16) not null,
+expires_at datetime not null,
+version integer not null default 1,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime
+);
+
+create unique index members_card_number_idx on members (card_number) where deleted_at is null;
+create index members_created_at_id_idx on members (created_at, id);
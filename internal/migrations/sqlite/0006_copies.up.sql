@@ -0,0 +1,19 @@
+create table copies (
+id integer primary key autoincrement,
+book_id integer not null,
+barcode varchar(32) not null,
+location varchar(64) not null default '',
+acquired_at datetime,
+price_cents integer not null default 0,
+condition varchar(16) not null,
+status varchar(16) not null,
+version integer not null default 1,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime,
+constraint fk_book foreign key (book_id) references books(id)
+);
+
+create unique index copies_barcode_idx on copies (barcode) where deleted_at is null;
+create index copies_book_id_status_idx on copies (book_id, status) where deleted_at is null;
+create index copies_created_at_id_idx on copies (created_at, id);
@@ -0,0 +1,18 @@
+create table loans (
+id integer primary key autoincrement,
+copy_id integer not null,
+member_id integer not null,
+loaned_at datetime not null,
+due_at datetime not null,
+returned_at datetime,
+created_at datetime,
+updated_at datetime,
+deleted_at datetime,
+constraint fk_copy foreign key (copy_id) references copies(id),
+constraint fk_member foreign key (member_id) references members(id)
+);
+
+-- A copy can only be on one open loan, whatever the application does.
+create unique index loans_open_copy_id_idx on loans (copy_id) where returned_at is null and deleted_at is null;
+create index loans_member_id_idx on loans (member_id, returned_at);
+create index loans_created_at_id_idx on loans (created_at, id);
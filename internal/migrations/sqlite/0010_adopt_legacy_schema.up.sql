@@ -0,0 +1,2 @@
+-- SQLite databases never predate versioned migrations, the version
+-- only keeps both drivers at the same schema version.
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// APIKey is a long-lived credential of a service, granting the
+// permissions listed in Scopes. Only a hash of its secret is stored,
+// the key itself is returned once, when it's created. A rotated key
+// keeps working until ExpiresAt and names its successor in ReplacedBy.
+type APIKey struct {
+	ID         string        `json:"id" gorm:"primaryKey"`
+	Name       string        `json:"name"`
+	Hash       string        `json:"-"`
+	Scopes     []APIKeyScope `json:"scopes" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedBy  uint          `json:"created_by"`
+	ExpiresAt  *time.Time    `json:"expires_at"`
+	RevokedAt  *time.Time    `json:"revoked_at"`
+	LastUsedAt *time.Time    `json:"last_used_at"`
+	ReplacedBy *string       `json:"replaced_by"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// APIKeyScope grants a permission to a key. It's rendered as the bare
+// scope name.
+type APIKeyScope struct {
+	APIKeyID string `gorm:"primaryKey"`
+	Scope    string `gorm:"primaryKey"`
+}
+
+func (s APIKeyScope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Scope)
+}
+
+func (s *APIKeyScope) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &s.Scope)
+}
+
+// NewAPIKey describes a key to create. Without ExpiresAt it doesn't
+// expire.
+type NewAPIKey struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,unique,dive,oneof=books:read books:write books:delete authors:read authors:write authors:delete"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyRotation replaces a key. The old key keeps working for
+// OverlapSeconds, 24 hours by default, so clients can switch without
+// downtime. The new key expires at ExpiresAt or never.
+type APIKeyRotation struct {
+	OverlapSeconds *int       `json:"overlap_seconds" validate:"omitempty,min=0,max=2592000"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is the only response that contains the key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
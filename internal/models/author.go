@@ -4,6 +4,7 @@ import "gorm.io/gorm"
 
 type Author struct {
 	gorm.Model
-	Name  string  `json:"name"`
-	Books []*Book `json:"books" gorm:"many2many:books_authors;"`
+	Name    string  `json:"name" validate:"required_if=ID 0,max=64"`
+	Version uint    `json:"version" gorm:"not null;default:1"`
+	Books   []*Book `json:"books" gorm:"many2many:books_authors;" validate:"dive,required"`
 }
@@ -4,7 +4,11 @@ import "gorm.io/gorm"
 
 type Book struct {
 	gorm.Model
-	Title   string    `json:"title"`
-	Pages   int       `json:"pages"`
-	Authors []*Author `json:"authors" gorm:"many2many:books_authors;"`
+	Title   string    `json:"title" validate:"required_if=ID 0,max=64"`
+	Pages   int       `json:"pages" validate:"min=0,max=100000"`
+	Version uint      `json:"version" gorm:"not null;default:1"`
+	Authors []*Author `json:"authors" gorm:"many2many:books_authors;" validate:"dive,required"`
+
+	// Availability is only filled in for a single book.
+	Availability *Availability `json:"availability,omitempty" gorm:"-" validate:"-"`
 }
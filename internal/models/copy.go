@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyOnHold    = "on_hold"
+	CopyInTransit = "in_transit"
+	CopyLost      = "lost"
+	CopyWithdrawn = "withdrawn"
+)
+
+const (
+	ConditionNew     = "new"
+	ConditionGood    = "good"
+	ConditionFair    = "fair"
+	ConditionPoor    = "poor"
+	ConditionDamaged = "damaged"
+)
+
+// Copy is a physical item of a book, identified by the barcode on it.
+// PriceCents is the acquisition price in minor currency units.
+// Condition defaults to good, Status to available on create and to the
+// stored status on update. A copy is on hold while it waits for the
+// member whose hold it was assigned to; on_loan and on_hold are only
+// set by checkouts, returns and holds.
+type Copy struct {
+	gorm.Model
+	BookID     uint       `json:"book_id"`
+	Barcode    string     `json:"barcode" validate:"required,max=32"`
+	Location   string     `json:"location" validate:"max=64"`
+	AcquiredAt *time.Time `json:"acquired_at"`
+	PriceCents int64      `json:"price_cents" validate:"min=0"`
+	Condition  string     `json:"condition" validate:"oneof=new good fair poor damaged"`
+	Status     string     `json:"status" validate:"omitempty,oneof=available on_loan on_hold in_transit lost withdrawn"`
+	Version    uint       `json:"version" gorm:"not null;default:1"`
+}
+
+// Availability counts the copies of a book by status. Total includes
+// lost and withdrawn copies.
+type Availability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+	OnLoan    int `json:"on_loan"`
+	OnHold    int `json:"on_hold"`
+	InTransit int `json:"in_transit"`
+	Lost      int `json:"lost"`
+	Withdrawn int `json:"withdrawn"`
+}
+
+// Add counts n copies with the status.
+func (a *Availability) Add(status string, n int) {
+	a.Total += n
+	switch status {
+	case CopyAvailable:
+		a.Available += n
+	case CopyOnLoan:
+		a.OnLoan += n
+	case CopyOnHold:
+		a.OnHold += n
+	case CopyInTransit:
+		a.InTransit += n
+	case CopyLost:
+		a.Lost += n
+	case CopyWithdrawn:
+		a.Withdrawn += n
+	}
+}
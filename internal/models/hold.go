@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// Hold queues a member for a book whose copies are all out. Holds are
+// served first come, first served: a returned copy is assigned to the
+// first waiting hold whose member can borrow, which makes it ready
+// until PickupBy. Position is the place of a waiting hold in the queue
+// of its book, starting at 1.
+type Hold struct {
+	gorm.Model
+	BookID   uint       `json:"book_id"`
+	MemberID uint       `json:"member_id"`
+	Status   string     `json:"status"`
+	CopyID   *uint      `json:"copy_id"`
+	ReadyAt  *time.Time `json:"ready_at"`
+	PickupBy *time.Time `json:"pickup_by"`
+	Position int        `json:"position,omitempty" gorm:"-"`
+}
+
+// NewHold places a hold for a member.
+type NewHold struct {
+	MemberID uint `json:"member_id" validate:"required"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Loan lends a copy to a member. It's open until the copy is returned,
+// loans are kept afterwards as the lending history. AssignedHold is
+// only filled in on return, with the hold the copy now waits for.
+type Loan struct {
+	gorm.Model
+	CopyID       uint       `json:"copy_id"`
+	MemberID     uint       `json:"member_id"`
+	LoanedAt     time.Time  `json:"loaned_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at"`
+	Renewals     int        `json:"renewals"`
+	AssignedHold *Hold      `json:"assigned_hold,omitempty" gorm:"-"`
+}
+
+// Checkout asks to lend a copy to a member.
+type Checkout struct {
+	CopyID   uint `json:"copy_id" validate:"required"`
+	MemberID uint `json:"member_id" validate:"required"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MemberAdult   = "adult"
+	MemberChild   = "child"
+	MemberStudent = "student"
+	MemberStaff   = "staff"
+)
+
+const (
+	MemberActive    = "active"
+	MemberSuspended = "suspended"
+	MemberExpired   = "expired"
+)
+
+// Member is a patron who borrows copies, identified by the number on
+// their library card. Type and Status default to adult and active.
+type Member struct {
+	gorm.Model
+	CardNumber string    `json:"card_number" validate:"required,max=32"`
+	Name       string    `json:"name" validate:"required,max=128"`
+	Email      string    `json:"email" validate:"omitempty,email,max=254"`
+	Phone      string    `json:"phone" validate:"max=32"`
+	Address    string    `json:"address" validate:"max=256"`
+	Type       string    `json:"type" validate:"oneof=adult child student staff"`
+	Status     string    `json:"status" validate:"oneof=active suspended expired"`
+	ExpiresAt  time.Time `json:"expires_at" validate:"required"`
+	Version    uint      `json:"version" gorm:"not null;default:1"`
+}
+
+// CanBorrow reports whether the member is active and their membership
+// hasn't run out at now.
+func (m Member) CanBorrow(now time.Time) bool {
+	return m.Status == MemberActive && now.Before(m.ExpiresAt)
+}
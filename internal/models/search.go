@@ -0,0 +1,14 @@
+package models
+
+const (
+	SearchTypeBook   = "book"
+	SearchTypeAuthor = "author"
+)
+
+type SearchResult struct {
+	Type    string  `json:"type"`
+	ID      uint    `json:"id"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
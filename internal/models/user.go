@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type User struct {
+	gorm.Model
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"`
+	Roles        []UserRole `json:"roles" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// UserRole assigns a role to a user. It's rendered as the bare role
+// name.
+type UserRole struct {
+	UserID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Role   string `gorm:"primaryKey"`
+}
+
+func (r UserRole) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Role)
+}
+
+func (r *UserRole) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &r.Role)
+}
+
+// RoleAssignment replaces the roles of a user.
+type RoleAssignment struct {
+	Roles []string `json:"roles" validate:"required,unique,dive,oneof=reader librarian cataloguer admin"`
+}
+
+// Session is a login of a user. It's identified by the refresh token,
+// of which only a hash is stored, and ends when it expires or is
+// revoked on logout.
+type Session struct {
+	ID          string `gorm:"primaryKey"`
+	UserID      uint
+	RefreshHash string
+	ExpiresAt   time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
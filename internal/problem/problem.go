@@ -0,0 +1,120 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+const (
+	CodeInvalidID            = "invalid_id"
+	CodeInvalidBody          = "invalid_body"
+	CodeInvalidQuery         = "invalid_query"
+	CodeValidationFailed     = "validation_failed"
+	CodeNotFound             = "not_found"
+	CodeBookNotFound         = "book_not_found"
+	CodeAuthorNotFound       = "author_not_found"
+	CodeMemberNotFound       = "member_not_found"
+	CodeCopyNotFound         = "copy_not_found"
+	CodeLoanNotFound         = "loan_not_found"
+	CodeHoldNotFound         = "hold_not_found"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePatchTestFailed      = "patch_test_failed"
+	CodePatchFailed          = "patch_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeUserNotFound         = "user_not_found"
+	CodeLastAdmin            = "last_admin"
+	CodeAPIKeyNotFound       = "api_key_not_found"
+	CodeAPIKeyInactive       = "api_key_inactive"
+	CodeCopyUnavailable      = "copy_unavailable"
+	CodeMemberInactive       = "member_inactive"
+	CodeLoanLimitReached     = "loan_limit_reached"
+	CodeLoanReturned         = "loan_returned"
+	CodeCopyOnHold           = "copy_on_hold"
+	CodeCirculationStatus    = "circulation_status"
+	CodeRenewalLimitReached  = "renewal_limit_reached"
+	CodeHoldPending          = "hold_pending"
+	CodeLoanOverdue          = "loan_overdue"
+	CodeCopiesAvailable      = "copies_available"
+	CodeNoCopies             = "no_copies"
+	CodeHoldExists           = "hold_exists"
+	CodeHoldClosed           = "hold_closed"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError describes a problem with a single request field, Field
+// is a JSON path such as "authors[0].name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object. Code is a stable
+// machine-readable identifier, the type URI is derived from it.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+
+	cause error
+}
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:library:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func Newf(status int, code, format string, args ...any) *Problem {
+	return New(status, code, fmt.Sprintf(format, args...))
+}
+
+// Internal hides err from the client, it's kept as the cause so the
+// error handler can log it.
+func Internal(err error) *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "The server failed to process the request.").WithCause(err)
+}
+
+// CodeForStatus returns the generic code for an HTTP status, e.g.
+// "method_not_allowed" for 405.
+func CodeForStatus(status int) string {
+	if status == http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func (p *Problem) WithCause(err error) *Problem {
+	p.cause = err
+	return p
+}
+
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", p.Code, p.Detail, p.cause)
+	}
+	return fmt.Sprintf("%s: %s", p.Code, p.Detail)
+}
+
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
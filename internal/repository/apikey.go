@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lastUsedPrecision limits how often a key's last use is written, so
+// clients reading the API don't turn every request into a write.
+const lastUsedPrecision = time.Minute
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create stores the key together with its scopes.
+func (kr APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.Create")
+	defer span.End()
+
+	return kr.db.WithContext(ctx).Create(key).Error
+}
+
+func (kr APIKeyRepository) Read(ctx context.Context, id string) (key *models.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.Read")
+	defer span.End()
+
+	err = kr.db.WithContext(ctx).Preload("Scopes").Where("id = ?", id).First(&key).Error
+	return key, err
+}
+
+// ReadAll returns every key, including expired and revoked ones,
+// oldest first.
+func (kr APIKeyRepository) ReadAll(ctx context.Context) (keys []*models.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.ReadAll")
+	defer span.End()
+
+	err = kr.db.WithContext(ctx).Preload("Scopes").Order("created_at").Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// Use returns the scopes of the key if it's active and hash matches
+// its secret, and records when it was last used.
+func (kr APIKeyRepository) Use(ctx context.Context, id, hash string) (scopes []string, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.Use")
+	defer span.End()
+
+	now := kr.db.NowFunc()
+	var key models.APIKey
+	err = kr.db.WithContext(ctx).Preload("Scopes").
+		Where("id = ? and revoked_at is null and (expires_at is null or expires_at > ?)", id, now).
+		First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+		return nil, false, nil
+	}
+
+	err = kr.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? and (last_used_at is null or last_used_at < ?)", id, now.Add(-lastUsedPrecision)).
+		UpdateColumn("last_used_at", now).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, s := range key.Scopes {
+		scopes = append(scopes, s.Scope)
+	}
+	return scopes, true, nil
+}
+
+// Rotate stores newKey as the successor of the active key id, with the
+// same name and scopes. The old key expires after overlap, unless it
+// expires sooner anyway. It returns the old key.
+func (kr APIKeyRepository) Rotate(ctx context.Context, id string, newKey *models.APIKey, overlap time.Duration) (old *models.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.Rotate")
+	defer span.End()
+
+	err = kr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Scopes").Where("id = ?", id).First(&old).Error; err != nil {
+			return err
+		}
+
+		now := tx.NowFunc()
+		if old.RevokedAt != nil || old.ReplacedBy != nil || (old.ExpiresAt != nil && !old.ExpiresAt.After(now)) {
+			return ErrAPIKeyInactive
+		}
+
+		newKey.Name = old.Name
+		newKey.Scopes = nil
+		for _, s := range old.Scopes {
+			newKey.Scopes = append(newKey.Scopes, models.APIKeyScope{Scope: s.Scope})
+		}
+		if err := tx.Create(newKey).Error; err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(overlap)
+		if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+			expiresAt = *old.ExpiresAt
+		}
+		old.ExpiresAt = &expiresAt
+		old.ReplacedBy = &newKey.ID
+		return tx.Model(old).Select("expires_at", "replaced_by").Updates(old).Error
+	})
+
+	return old, err
+}
+
+// Revoke ends the key immediately. Revoking a revoked key succeeds.
+func (kr APIKeyRepository) Revoke(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "APIKeyRepository.Revoke")
+	defer span.End()
+
+	return kr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var key models.APIKey
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&key).Error; err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return nil
+		}
+
+		return tx.Model(&key).Update("revoked_at", tx.NowFunc()).Error
+	})
+}
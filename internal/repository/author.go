@@ -1,54 +1,153 @@
 package repository
 
 import (
+	"context"
 	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthorRepository struct {
 	db *gorm.DB
 }
 
+var authorListSpec = listSpec{
+	table: "authors",
+	filters: merge(map[string]filterFunc{
+		"name":    equalFilter("authors.name"),
+		"name~":   containsFilter("authors.name"),
+		"book_id": idFilter("authors.id", "select author_id from books_authors where book_id = ?"),
+	}, timestampFilters("authors")),
+	sorts: map[string]string{
+		"id":         "authors.id",
+		"name":       "authors.name",
+		"created_at": "authors.created_at",
+		"updated_at": "authors.updated_at",
+	},
+}
+
 func NewAuthorRepository(db *gorm.DB) *AuthorRepository {
 	return &AuthorRepository{db: db}
 }
 
-func (ar AuthorRepository) Create(author *models.Author) error {
-	return ar.db.Create(author).Error
+func (ar AuthorRepository) Create(ctx context.Context, author *models.Author) error {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.Create")
+	defer span.End()
+
+	return ar.db.WithContext(ctx).Create(author).Error
 }
 
-func (ar AuthorRepository) Read(id uint) (author *models.Author, err error) {
-	err = ar.db.Preload("Books").First(&author, id).Error
+func (ar AuthorRepository) Read(ctx context.Context, id uint, preloads ...string) (author *models.Author, err error) {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.Read")
+	defer span.End()
+
+	tx := ar.db.WithContext(ctx)
+	for _, preload := range preloads {
+		tx = tx.Preload(preload)
+	}
+
+	err = tx.First(&author, id).Error
 	return author, err
 }
 
-func (ar AuthorRepository) ReadAll() (authors []*models.Author, err error) {
-	err = ar.db.Preload("Books").Find(&authors).Error
-	return authors, err
+func (ar AuthorRepository) ReadAll(ctx context.Context, q ListQuery) (authors []*models.Author, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.ReadAll")
+	defer span.End()
+
+	base := ar.db.WithContext(ctx).Model(&models.Author{}).Session(&gorm.Session{})
+	return paginate(base, authorListSpec, q, authorCursor)
+}
+
+// Update replaces the stored author with newAuthor, including zero
+// values. A nil Books list detaches every book. A non-zero version
+// must match the stored one, otherwise ErrVersionMismatch is returned.
+func (ar AuthorRepository) Update(ctx context.Context, id, version uint, newAuthor *models.Author) error {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.Update")
+	defer span.End()
+
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		author, err := lockAuthor(tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		return replaceAuthor(tx, author, newAuthor)
+	})
 }
 
-func (ar AuthorRepository) Update(id uint, newAuthor *models.Author) error {
-	return ar.db.Transaction(func(tx *gorm.DB) error {
-		var author models.Author
-		if err := tx.First(&author, id).Error; err != nil {
+// Patch locks the stored author, lets patch modify a copy of it and
+// saves the result like Update. It returns the author as stored.
+func (ar AuthorRepository) Patch(ctx context.Context, id, version uint, patch func(author *models.Author) error) (patched *models.Author, err error) {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.Patch")
+	defer span.End()
+
+	err = ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		author, err := lockAuthor(tx.Preload("Books"), id, version)
+		if err != nil {
+			return err
+		}
+
+		newAuthor := *author
+		if err := patch(&newAuthor); err != nil {
 			return err
 		}
 
-		if err := tx.Model(&author).Updates(newAuthor).Error; err != nil {
+		if err := replaceAuthor(tx, author, &newAuthor); err != nil {
 			return err
 		}
 
-		if newAuthor.Books != nil {
-			err := tx.Model(&author).Association("Books").Replace(newAuthor.Books)
-			if err != nil {
-				return err
-			}
+		return tx.Preload("Books").First(&patched, id).Error
+	})
+
+	return patched, err
+}
+
+func (ar AuthorRepository) Delete(ctx context.Context, id, version uint) error {
+	ctx, span := tracing.Start(ctx, "AuthorRepository.Delete")
+	defer span.End()
+
+	if version == 0 {
+		result := ar.db.WithContext(ctx).Select("Books").Delete(&models.Author{Model: gorm.Model{ID: id}})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	}
+
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		author, err := lockAuthor(tx, id, version)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		return tx.Select("Books").Delete(author).Error
 	})
 }
 
-func (ar AuthorRepository) Delete(id uint) error {
-	return ar.db.Select("Books").Delete(&models.Author{Model: gorm.Model{ID: id}}).Error
+func authorCursor(author *models.Author) Cursor {
+	return Cursor{CreatedAt: author.CreatedAt, ID: author.ID}
+}
+
+func lockAuthor(tx *gorm.DB, id, version uint) (*models.Author, error) {
+	var author models.Author
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&author, id).Error; err != nil {
+		return nil, err
+	}
+
+	if version != 0 && author.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return &author, nil
+}
+
+func replaceAuthor(tx *gorm.DB, author, newAuthor *models.Author) error {
+	newAuthor.ID = author.ID
+	newAuthor.Version = author.Version + 1
+	if err := tx.Model(author).Select("name", "version").Updates(newAuthor).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(author).Association("Books").Replace(newAuthor.Books)
 }
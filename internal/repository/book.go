@@ -1,54 +1,188 @@
 package repository
 
 import (
+	"context"
+	"slices"
+
 	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BookRepository struct {
 	db *gorm.DB
 }
 
+var bookListSpec = listSpec{
+	table: "books",
+	filters: merge(map[string]filterFunc{
+		"title":     equalFilter("books.title"),
+		"title~":    containsFilter("books.title"),
+		"pages_min": intFilter("books.pages", ">="),
+		"pages_max": intFilter("books.pages", "<="),
+		"author_id": idFilter("books.id", "select book_id from books_authors where author_id = ?"),
+	}, timestampFilters("books")),
+	sorts: map[string]string{
+		"id":         "books.id",
+		"title":      "books.title",
+		"pages":      "books.pages",
+		"created_at": "books.created_at",
+		"updated_at": "books.updated_at",
+	},
+}
+
 func NewBookRepository(db *gorm.DB) *BookRepository {
 	return &BookRepository{db: db}
 }
 
-func (br BookRepository) Create(book *models.Book) error {
-	return br.db.Create(book).Error
+func (br BookRepository) Create(ctx context.Context, book *models.Book) error {
+	ctx, span := tracing.Start(ctx, "BookRepository.Create")
+	defer span.End()
+
+	return br.db.WithContext(ctx).Create(book).Error
 }
 
-func (br BookRepository) Read(id uint) (book *models.Book, err error) {
-	err = br.db.Preload("Authors").First(&book, id).Error
+func (br BookRepository) Read(ctx context.Context, id uint, preloads ...string) (book *models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookRepository.Read")
+	defer span.End()
+
+	tx := br.db.WithContext(ctx)
+	for _, preload := range preloads {
+		tx = tx.Preload(preload)
+	}
+
+	err = tx.First(&book, id).Error
 	return book, err
 }
 
-func (br BookRepository) ReadAll() (books []*models.Book, err error) {
-	err = br.db.Preload("Authors").Find(&books).Error
-	return books, err
+func (br BookRepository) ReadAll(ctx context.Context, q ListQuery) (books []*models.Book, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "BookRepository.ReadAll")
+	defer span.End()
+
+	base := br.db.WithContext(ctx).Model(&models.Book{}).Session(&gorm.Session{})
+	return paginate(base, bookListSpec, q, bookCursor)
 }
 
-func (br BookRepository) Update(id uint, newBook *models.Book) error {
-	return br.db.Transaction(func(tx *gorm.DB) error {
-		var book models.Book
-		if err := tx.First(&book, id).Error; err != nil {
+// Update replaces the stored book with newBook, including zero
+// values. A nil Authors list detaches every author. A non-zero version
+// must match the stored one, otherwise ErrVersionMismatch is returned.
+func (br BookRepository) Update(ctx context.Context, id, version uint, newBook *models.Book) error {
+	ctx, span := tracing.Start(ctx, "BookRepository.Update")
+	defer span.End()
+
+	return br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		book, err := lockBook(tx, id, version)
+		if err != nil {
 			return err
 		}
 
-		if err := tx.Model(&book).Updates(newBook).Error; err != nil {
+		return replaceBook(tx, book, newBook)
+	})
+}
+
+// Patch locks the stored book, lets patch modify a copy of it and
+// saves the result like Update. It returns the book as stored.
+func (br BookRepository) Patch(ctx context.Context, id, version uint, patch func(book *models.Book) error) (patched *models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookRepository.Patch")
+	defer span.End()
+
+	err = br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		book, err := lockBook(tx.Preload("Authors"), id, version)
+		if err != nil {
+			return err
+		}
+
+		newBook := *book
+		if err := patch(&newBook); err != nil {
+			return err
+		}
+
+		if err := replaceBook(tx, book, &newBook); err != nil {
 			return err
 		}
 
-		if newBook.Authors != nil {
-			err := tx.Model(&book).Association("Authors").Replace(newBook.Authors)
-			if err != nil {
-				return err
-			}
+		return tx.Preload("Authors").First(&patched, id).Error
+	})
+
+	return patched, err
+}
+
+func (br BookRepository) Delete(ctx context.Context, id, version uint) error {
+	ctx, span := tracing.Start(ctx, "BookRepository.Delete")
+	defer span.End()
+
+	if version == 0 {
+		result := br.db.WithContext(ctx).Select("Authors").Delete(&models.Book{Model: gorm.Model{ID: id}})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	}
+
+	return br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		book, err := lockBook(tx, id, version)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		return tx.Select("Authors").Delete(book).Error
 	})
 }
 
-func (br BookRepository) Delete(id uint) error {
-	return br.db.Select("Authors").Delete(&models.Book{Model: gorm.Model{ID: id}}).Error
+// MissingAuthors returns the ids that don't belong to a stored author.
+func (br BookRepository) MissingAuthors(ctx context.Context, ids []uint) (missing []uint, err error) {
+	ctx, span := tracing.Start(ctx, "BookRepository.MissingAuthors")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []uint
+	if err = br.db.WithContext(ctx).Model(&models.Author{}).Where("id in ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if !slices.Contains(found, id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// Availability counts the book's copies by status.
+func (br BookRepository) Availability(ctx context.Context, id uint) (*models.Availability, error) {
+	ctx, span := tracing.Start(ctx, "BookRepository.Availability")
+	defer span.End()
+
+	return copyAvailability(br.db.WithContext(ctx), id)
+}
+
+func bookCursor(book *models.Book) Cursor {
+	return Cursor{CreatedAt: book.CreatedAt, ID: book.ID}
+}
+
+func lockBook(tx *gorm.DB, id, version uint) (*models.Book, error) {
+	var book models.Book
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error; err != nil {
+		return nil, err
+	}
+
+	if version != 0 && book.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return &book, nil
+}
+
+func replaceBook(tx *gorm.DB, book, newBook *models.Book) error {
+	newBook.ID = book.ID
+	newBook.Version = book.Version + 1
+	if err := tx.Model(book).Select("title", "pages", "version").Updates(newBook).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(book).Association("Authors").Replace(newBook.Authors)
 }
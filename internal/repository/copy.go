@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CopyRepository persists the copies of books. Every method takes the
+// id of the book, a missing book is reported as ErrBookNotFound and a
+// copy of another book as gorm.ErrRecordNotFound. Copies that become
+// available go to the hold queue of their book first, following policy.
+type CopyRepository struct {
+	db     *gorm.DB
+	policy config.Circulation
+}
+
+var copyListSpec = listSpec{
+	table: "copies",
+	filters: merge(map[string]filterFunc{
+		"barcode":   equalFilter("copies.barcode"),
+		"location":  equalFilter("copies.location"),
+		"location~": containsFilter("copies.location"),
+		"condition": equalFilter("copies.condition"),
+		"status":    equalFilter("copies.status"),
+	}, timestampFilters("copies")),
+	sorts: map[string]string{
+		"id":          "copies.id",
+		"barcode":     "copies.barcode",
+		"location":    "copies.location",
+		"acquired_at": "copies.acquired_at",
+		"created_at":  "copies.created_at",
+		"updated_at":  "copies.updated_at",
+	},
+}
+
+func NewCopyRepository(db *gorm.DB, policy config.Circulation) *CopyRepository {
+	return &CopyRepository{db: db, policy: policy}
+}
+
+func (cr CopyRepository) Create(ctx context.Context, bookID uint, item *models.Copy) error {
+	ctx, span := tracing.Start(ctx, "CopyRepository.Create")
+	defer span.End()
+
+	return cr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The lock keeps the book from being deleted meanwhile and
+		// its queue from changing, like on return.
+		if err := findBook(tx.Clauses(clause.Locking{Strength: "UPDATE"}), bookID); err != nil {
+			return err
+		}
+
+		if item.Status == "" {
+			item.Status = models.CopyAvailable
+		}
+		if circulating(item.Status) {
+			return ErrCirculationStatus
+		}
+
+		hold, err := cr.holdFor(tx, bookID, item)
+		if err != nil {
+			return err
+		}
+
+		item.BookID = bookID
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		if hold != nil {
+			return readyHold(tx, hold, item.ID, cr.policy)
+		}
+		return nil
+	})
+}
+
+func (cr CopyRepository) Read(ctx context.Context, bookID, id uint) (item *models.Copy, err error) {
+	ctx, span := tracing.Start(ctx, "CopyRepository.Read")
+	defer span.End()
+
+	tx := cr.db.WithContext(ctx)
+	if err := findBook(tx, bookID); err != nil {
+		return nil, err
+	}
+
+	err = tx.Where("book_id = ?", bookID).First(&item, id).Error
+	return item, err
+}
+
+func (cr CopyRepository) ReadAll(ctx context.Context, bookID uint, q ListQuery) (copies []*models.Copy, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "CopyRepository.ReadAll")
+	defer span.End()
+
+	tx := cr.db.WithContext(ctx)
+	if err := findBook(tx, bookID); err != nil {
+		return nil, page, err
+	}
+
+	base := tx.Model(&models.Copy{}).Where("copies.book_id = ?", bookID).Session(&gorm.Session{})
+	return paginate(base, copyListSpec, q, copyCursor)
+}
+
+// Update replaces the stored item with newCopy, including zero values,
+// except that an empty status keeps the stored one. A non-zero version
+// must match the stored one, otherwise ErrVersionMismatch is returned.
+// Moving the copy into or out of on_loan or on_hold fails with
+// ErrCirculationStatus.
+func (cr CopyRepository) Update(ctx context.Context, bookID, id, version uint, newCopy *models.Copy) error {
+	ctx, span := tracing.Start(ctx, "CopyRepository.Update")
+	defer span.End()
+
+	return cr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		item, err := lockCopy(tx, bookID, id, version)
+		if err != nil {
+			return err
+		}
+
+		if newCopy.Status == "" {
+			newCopy.Status = item.Status
+		}
+		if newCopy.Status != item.Status && (circulating(item.Status) || circulating(newCopy.Status)) {
+			return ErrCirculationStatus
+		}
+
+		var hold *models.Hold
+		if item.Status != models.CopyAvailable {
+			if hold, err = cr.holdFor(tx, item.BookID, newCopy); err != nil {
+				return err
+			}
+		}
+
+		newCopy.ID = item.ID
+		newCopy.BookID = item.BookID
+		newCopy.CreatedAt = item.CreatedAt
+		newCopy.Version = item.Version + 1
+		err = tx.Model(item).
+			Select("barcode", "location", "acquired_at", "price_cents", "condition", "status", "version").
+			Updates(newCopy).Error
+		if err != nil || hold == nil {
+			return err
+		}
+		return readyHold(tx, hold, item.ID, cr.policy)
+	})
+}
+
+// Delete removes the copy unless it's on loan or on hold, which fails
+// with ErrCirculationStatus.
+func (cr CopyRepository) Delete(ctx context.Context, bookID, id, version uint) error {
+	ctx, span := tracing.Start(ctx, "CopyRepository.Delete")
+	defer span.End()
+
+	return cr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		item, err := lockCopy(tx, bookID, id, version)
+		if err != nil {
+			return err
+		}
+		if circulating(item.Status) {
+			return ErrCirculationStatus
+		}
+
+		return tx.Delete(item).Error
+	})
+}
+
+// holdFor returns the hold an available item goes to instead of the
+// shelf and puts the item on hold for it, or nil if nobody is waiting.
+func (cr CopyRepository) holdFor(tx *gorm.DB, bookID uint, item *models.Copy) (*models.Hold, error) {
+	if item.Status != models.CopyAvailable {
+		return nil, nil
+	}
+
+	hold, err := nextHold(tx, bookID, cr.policy)
+	if hold != nil {
+		item.Status = models.CopyOnHold
+	}
+	return hold, err
+}
+
+// circulating reports whether status is set by loans and holds only.
+func circulating(status string) bool {
+	return status == models.CopyOnLoan || status == models.CopyOnHold
+}
+
+func copyCursor(item *models.Copy) Cursor {
+	return Cursor{CreatedAt: item.CreatedAt, ID: item.ID}
+}
+
+func findBook(tx *gorm.DB, id uint) error {
+	err := tx.Select("id").First(&models.Book{}, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookNotFound
+	}
+	return err
+}
+
+func lockCopy(tx *gorm.DB, bookID, id, version uint) (*models.Copy, error) {
+	if err := findBook(tx, bookID); err != nil {
+		return nil, err
+	}
+
+	var item models.Copy
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("book_id = ?", bookID).First(&item, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && item.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return &item, nil
+}
+
+// copyAvailability counts the copies of a book by status.
+func copyAvailability(tx *gorm.DB, bookID uint) (*models.Availability, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err := tx.Model(&models.Copy{}).Select("status, count(*) as count").
+		Where("book_id = ?", bookID).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var a models.Availability
+	for _, row := range rows {
+		a.Add(row.Status, row.Count)
+	}
+	return &a, nil
+}
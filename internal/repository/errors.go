@@ -0,0 +1,76 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrVersionMismatch is returned when a write expects a version of
+	// the record that is no longer current.
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	// ErrBookNotFound is returned when the book a record belongs to
+	// doesn't exist, to tell it apart from a missing record.
+	ErrBookNotFound = errors.New("book not found")
+
+	// ErrCopyNotFound and ErrMemberNotFound are returned when a
+	// checkout names a copy or member that doesn't exist.
+	ErrCopyNotFound   = errors.New("copy not found")
+	ErrMemberNotFound = errors.New("member not found")
+
+	// ErrCopyUnavailable is returned when checking out a copy that
+	// isn't available, e.g. because it's already on loan.
+	ErrCopyUnavailable = errors.New("copy unavailable")
+
+	// ErrMemberInactive is returned when lending to a member who is
+	// suspended or whose membership has expired.
+	ErrMemberInactive = errors.New("member inactive")
+
+	// ErrLoanLimitReached is returned when a member already has as
+	// many copies on loan as the policy allows.
+	ErrLoanLimitReached = errors.New("loan limit reached")
+
+	// ErrCopyOnHold is returned when checking out a copy that is held
+	// for another member.
+	ErrCopyOnHold = errors.New("copy on hold for another member")
+
+	// ErrLoanReturned is returned when returning a loan twice.
+	ErrLoanReturned = errors.New("loan already returned")
+
+	// ErrRenewalLimitReached, ErrHoldPending and ErrLoanOverdue are
+	// returned when a loan can't be renewed because it was renewed as
+	// often as the policy allows, another member holds the book, or
+	// it's overdue by more than the grace period.
+	ErrRenewalLimitReached = errors.New("renewal limit reached")
+	ErrHoldPending         = errors.New("hold pending")
+	ErrLoanOverdue         = errors.New("loan overdue")
+
+	// ErrCopiesAvailable and ErrNoCopiesToHold are returned when placing
+	// a hold on a book that has a copy on the shelf, or no copy that
+	// could come back to it.
+	ErrCopiesAvailable = errors.New("copies available")
+	ErrNoCopiesToHold  = errors.New("no copies to hold")
+
+	// ErrHoldExists is returned when a member places a second hold on
+	// the same book.
+	ErrHoldExists = errors.New("hold exists")
+
+	// ErrHoldClosed is returned when cancelling a hold that was already
+	// fulfilled, cancelled or expired.
+	ErrHoldClosed = errors.New("hold closed")
+
+	// ErrCirculationStatus is returned when a copy edit moves it into
+	// or out of on_loan or on_hold, which only circulation may do.
+	ErrCirculationStatus = errors.New("status managed by circulation")
+
+	// ErrSessionEnded is returned for refresh tokens of expired or
+	// revoked sessions, and for refresh tokens that were already
+	// replaced, which also revokes their session.
+	ErrSessionEnded = errors.New("session ended")
+
+	// ErrLastRoleHolder is returned when a role change would leave a
+	// role that must stay assigned without any user.
+	ErrLastRoleHolder = errors.New("last user with role")
+
+	// ErrAPIKeyInactive is returned when rotating a key that expired,
+	// was revoked or was already rotated.
+	ErrAPIKeyInactive = errors.New("api key inactive")
+)
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InvalidQueryError reports a listing parameter that is unknown or
+// has a malformed value.
+type InvalidQueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *InvalidQueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %s", e.Param, e.Reason)
+}
+
+type filterFunc func(tx *gorm.DB, value string) (*gorm.DB, error)
+
+// listSpec whitelists the filters and sort keys of a listing. Filter
+// keys are the raw query parameter names, e.g. "title~" or "pages_min".
+type listSpec struct {
+	table   string
+	filters map[string]filterFunc
+	sorts   map[string]string
+}
+
+func (s listSpec) apply(tx *gorm.DB, q ListQuery) (*gorm.DB, error) {
+	for param, value := range q.Filters {
+		filter, ok := s.filters[param]
+		if !ok {
+			return nil, &InvalidQueryError{Param: param, Reason: "unknown filter"}
+		}
+
+		var err error
+		if tx, err = filter(tx, value); err != nil {
+			return nil, &InvalidQueryError{Param: param, Reason: err.Error()}
+		}
+	}
+
+	return tx, nil
+}
+
+func (s listSpec) order(tx *gorm.DB, sort []string) (*gorm.DB, error) {
+	for _, key := range sort {
+		field, desc := strings.CutPrefix(key, "-")
+		column, ok := s.sorts[field]
+		if !ok {
+			return nil, &InvalidQueryError{Param: "sort", Reason: fmt.Sprintf("unknown field %q", field)}
+		}
+
+		if desc {
+			column += " desc"
+		}
+		tx = tx.Order(column)
+	}
+
+	return tx.Order(s.table + ".id"), nil
+}
+
+func containsFilter(column string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		pattern := "%" + escapeLike(value) + "%"
+		if tx.Dialector.Name() == "sqlite" {
+			// SQLite's like ignores case, but only for ASCII letters,
+			// and has no default escape character.
+			return tx.Where(column+` like ? escape '\'`, pattern), nil
+		}
+		return tx.Where(column+" ilike ?", pattern), nil
+	}
+}
+
+// anyFilter matches rows that match any of filters.
+func anyFilter(filters ...filterFunc) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		var cond *gorm.DB
+		for _, filter := range filters {
+			c, err := filter(tx.Session(&gorm.Session{NewDB: true}), value)
+			if err != nil {
+				return nil, err
+			}
+			if cond == nil {
+				cond = c
+			} else {
+				cond = cond.Or(c)
+			}
+		}
+		return tx.Where(cond), nil
+	}
+}
+
+func equalFilter(column string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		return tx.Where(column+" = ?", value), nil
+	}
+}
+
+func intFilter(column, op string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		n, err := parseInt(value)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Where(column+" "+op+" ?", n), nil
+	}
+}
+
+// timeFilter compares in UTC, SQLite stores timestamps as UTC text.
+func timeFilter(column, op string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		t, err := parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Where(column+" "+op+" ?", t.UTC()), nil
+	}
+}
+
+// setFilter matches rows whose column is set for "true" and rows
+// whose column is null for "false".
+func setFilter(column string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		set, err := parseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if set {
+			return tx.Where(column + " is not null"), nil
+		}
+		return tx.Where(column + " is null"), nil
+	}
+}
+
+// idFilter matches rows whose column is in the result of subquery,
+// which takes the parsed id as its only argument.
+func idFilter(column, subquery string) filterFunc {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		id, err := parseID(value)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Where(column+" in ("+subquery+")", id), nil
+	}
+}
+
+func timestampFilters(table string) map[string]filterFunc {
+	return map[string]filterFunc{
+		"created_after":  timeFilter(table+".created_at", ">"),
+		"created_before": timeFilter(table+".created_at", "<"),
+		"updated_after":  timeFilter(table+".updated_at", ">"),
+		"updated_before": timeFilter(table+".updated_at", "<"),
+	}
+}
+
+// The parse functions below are shared by the SQL and the in-memory
+// filters, so both reject the same values with the same reasons.
+
+func parseInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer")
+	}
+	return n, nil
+}
+
+func parseBool(value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("expected true or false")
+	}
+	return b, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, value); err != nil {
+			return t, fmt.Errorf("expected an RFC 3339 timestamp or a date")
+		}
+	}
+	return t, nil
+}
+
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("expected an id")
+	}
+	return uint(id), nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func merge[F any](filters ...map[string]F) map[string]F {
+	merged := make(map[string]F)
+	for _, f := range filters {
+		maps.Copy(merged, f)
+	}
+	return merged
+}
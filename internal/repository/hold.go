@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HoldRepository keeps the hold queue of every book. Copies are
+// assigned to the queue when they come back, on return, on cancelling
+// a ready hold and when a pickup is missed.
+type HoldRepository struct {
+	db     *gorm.DB
+	policy config.Circulation
+}
+
+var holdListSpec = listSpec{
+	table: "holds",
+	filters: merge(map[string]filterFunc{
+		"status": equalFilter("holds.status"),
+	}, timestampFilters("holds")),
+	sorts: map[string]string{
+		"id":         "holds.id",
+		"pickup_by":  "holds.pickup_by",
+		"created_at": "holds.created_at",
+		"updated_at": "holds.updated_at",
+	},
+}
+
+// activeHolds are the statuses of holds still in the queue.
+var activeHolds = []string{models.HoldWaiting, models.HoldReady}
+
+func NewHoldRepository(db *gorm.DB, policy config.Circulation) *HoldRepository {
+	return &HoldRepository{db: db, policy: policy}
+}
+
+// Place queues the member for the book. It's only possible while no
+// copy of the book is available and some copy is out, and once per
+// member and book. A missing book is reported as ErrBookNotFound.
+func (hr HoldRepository) Place(ctx context.Context, bookID, memberID uint) (hold *models.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.Place")
+	defer span.End()
+
+	err = hr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Returns lock the book too before looking at its queue, so a
+		// copy can't come back unnoticed while the hold is placed.
+		if err := findBook(tx.Clauses(clause.Locking{Strength: "UPDATE"}), bookID); err != nil {
+			return err
+		}
+
+		var member models.Member
+		err := tx.First(&member, memberID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !member.CanBorrow(tx.NowFunc()) {
+			return ErrMemberInactive
+		}
+
+		a, err := copyAvailability(tx, bookID)
+		if err != nil {
+			return err
+		}
+		if a.Available > 0 {
+			return ErrCopiesAvailable
+		}
+		if a.OnLoan+a.OnHold+a.InTransit == 0 {
+			return ErrNoCopiesToHold
+		}
+
+		var active int64
+		err = tx.Model(&models.Hold{}).
+			Where("book_id = ? and member_id = ? and status in ?", bookID, memberID, activeHolds).
+			Count(&active).Error
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrHoldExists
+		}
+
+		hold = &models.Hold{BookID: bookID, MemberID: memberID, Status: models.HoldWaiting}
+		if err := tx.Create(hold).Error; err != nil {
+			return err
+		}
+		return fillPositions(tx, hold)
+	})
+
+	return hold, err
+}
+
+func (hr HoldRepository) Read(ctx context.Context, id uint) (hold *models.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.Read")
+	defer span.End()
+
+	tx := hr.db.WithContext(ctx)
+	if err := tx.First(&hold, id).Error; err != nil {
+		return nil, err
+	}
+	return hold, fillPositions(tx, hold)
+}
+
+// ReadByBook lists the queue of the book, the waiting and ready holds
+// in the order they were placed. A missing book is reported as
+// ErrBookNotFound.
+func (hr HoldRepository) ReadByBook(ctx context.Context, bookID uint, q ListQuery) (holds []*models.Hold, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.ReadByBook")
+	defer span.End()
+
+	tx := hr.db.WithContext(ctx)
+	if err := findBook(tx, bookID); err != nil {
+		return nil, page, err
+	}
+
+	base := tx.Model(&models.Hold{}).
+		Where("holds.book_id = ? and holds.status in ?", bookID, activeHolds).
+		Session(&gorm.Session{})
+	if holds, page, err = paginate(base, holdListSpec, q, holdCursor); err != nil {
+		return nil, page, err
+	}
+	return holds, page, fillPositions(tx, holds...)
+}
+
+// ReadByMember lists every hold of the member, closed ones included.
+func (hr HoldRepository) ReadByMember(ctx context.Context, memberID uint, q ListQuery) (holds []*models.Hold, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.ReadByMember")
+	defer span.End()
+
+	tx := hr.db.WithContext(ctx)
+	if err := tx.Select("id").First(&models.Member{}, memberID).Error; err != nil {
+		return nil, page, err
+	}
+
+	base := tx.Model(&models.Hold{}).Where("holds.member_id = ?", memberID).Session(&gorm.Session{})
+	if holds, page, err = paginate(base, holdListSpec, q, holdCursor); err != nil {
+		return nil, page, err
+	}
+	return holds, page, fillPositions(tx, holds...)
+}
+
+// Cancel takes the hold out of the queue. The copy of a ready hold
+// goes to the next hold in line.
+func (hr HoldRepository) Cancel(ctx context.Context, id uint) (hold *models.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.Cancel")
+	defer span.End()
+
+	err = hr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&hold, id).Error; err != nil {
+			return err
+		}
+
+		// Copies are locked before their holds, like on checkout.
+		var item *models.Copy
+		if hold.CopyID != nil {
+			if item, err = lockCopyByID(tx.Unscoped(), *hold.CopyID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, id).Error; err != nil {
+			return err
+		}
+		if hold.Status != models.HoldWaiting && hold.Status != models.HoldReady {
+			return ErrHoldClosed
+		}
+
+		ready := hold.Status == models.HoldReady
+		hold.Status = models.HoldCancelled
+		if err := tx.Model(hold).Update("status", hold.Status).Error; err != nil {
+			return err
+		}
+
+		if !ready {
+			return nil
+		}
+		if item == nil || item.ID != *hold.CopyID {
+			// The hold became ready after it was first read.
+			if item, err = lockCopyByID(tx.Unscoped(), *hold.CopyID); err != nil {
+				return err
+			}
+		}
+		_, err := assignCopy(tx, item, hr.policy)
+		return err
+	})
+
+	return hold, err
+}
+
+// ExpirePickups expires the ready holds whose pickup deadline has
+// passed and passes their copies on. It returns how many expired.
+func (hr HoldRepository) ExpirePickups(ctx context.Context) (expired int, err error) {
+	ctx, span := tracing.Start(ctx, "HoldRepository.ExpirePickups")
+	defer span.End()
+
+	db := hr.db.WithContext(ctx)
+	var due []*models.Hold
+	err = db.Where("status = ? and pickup_by < ?", models.HoldReady, db.NowFunc()).
+		Order("pickup_by").Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, candidate := range due {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			item, err := lockCopyByID(tx.Unscoped(), *candidate.CopyID)
+			if err != nil {
+				return err
+			}
+
+			var hold models.Hold
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, candidate.ID).Error; err != nil {
+				return err
+			}
+			// The member may have picked it up meanwhile.
+			if hold.Status != models.HoldReady || !hold.PickupBy.Before(tx.NowFunc()) {
+				return nil
+			}
+
+			if err := tx.Model(&hold).Update("status", models.HoldExpired).Error; err != nil {
+				return err
+			}
+			expired++
+
+			_, err = assignCopy(tx, item, hr.policy)
+			return err
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// ExpiryWorker expires missed pickups every interval until ctx is
+// cancelled.
+func (hr HoldRepository) ExpiryWorker(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			n, err := hr.ExpirePickups(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error. Failed to expire hold pickups", "error", err)
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Hold pickups expired", "count", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+func holdCursor(hold *models.Hold) Cursor {
+	return Cursor{CreatedAt: hold.CreatedAt, ID: hold.ID}
+}
+
+// fillPositions sets the queue position of the waiting holds.
+func fillPositions(tx *gorm.DB, holds ...*models.Hold) error {
+	for _, hold := range holds {
+		if hold.Status != models.HoldWaiting {
+			continue
+		}
+
+		var ahead int64
+		err := tx.Model(&models.Hold{}).
+			Where("book_id = ? and status = ?", hold.BookID, models.HoldWaiting).
+			Where("(created_at, id) < (?, ?)", hold.CreatedAt.UTC(), hold.ID).
+			Count(&ahead).Error
+		if err != nil {
+			return err
+		}
+		hold.Position = int(ahead) + 1
+	}
+	return nil
+}
+
+// assignCopy gives a locked copy that came back to the first waiting
+// hold on its book whose member can borrow and is below the loan
+// limit, or puts it back on the shelf. It returns the hold, if any.
+func assignCopy(tx *gorm.DB, item *models.Copy, policy config.Circulation) (*models.Hold, error) {
+	var hold *models.Hold
+	if !item.DeletedAt.Valid {
+		var err error
+		if hold, err = nextHold(tx, item.BookID, policy); err != nil {
+			return nil, err
+		}
+	}
+	if hold == nil {
+		return nil, setCopyStatus(tx, item, models.CopyAvailable)
+	}
+
+	if err := readyHold(tx, hold, item.ID, policy); err != nil {
+		return nil, err
+	}
+	return hold, setCopyStatus(tx, item, models.CopyOnHold)
+}
+
+// nextHold locks the book and returns the first waiting hold on it
+// whose member can borrow and is below the loan limit, nil if there is
+// none or the book is gone.
+func nextHold(tx *gorm.DB, bookID uint, policy config.Circulation) (*models.Hold, error) {
+	if err := findBook(tx.Clauses(clause.Locking{Strength: "UPDATE"}), bookID); err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var hold models.Hold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "holds"}}).
+		Joins("join members on members.id = holds.member_id and members.deleted_at is null").
+		Where("holds.book_id = ? and holds.status = ?", bookID, models.HoldWaiting).
+		Where("members.status = ? and members.expires_at > ?", models.MemberActive, tx.NowFunc()).
+		Where("(select count(*) from loans where loans.member_id = holds.member_id"+
+			" and loans.returned_at is null and loans.deleted_at is null) < ?", policy.LoanLimit).
+		Order("holds.created_at").Order("holds.id").
+		Take(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// readyHold sets the copy aside for the member of hold until the end
+// of the pickup window.
+func readyHold(tx *gorm.DB, hold *models.Hold, copyID uint, policy config.Circulation) error {
+	now := tx.NowFunc()
+	pickupBy := now.Add(policy.PickupWindow)
+	hold.Status = models.HoldReady
+	hold.CopyID = &copyID
+	hold.ReadyAt = &now
+	hold.PickupBy = &pickupBy
+	return tx.Model(hold).Select("status", "copy_id", "ready_at", "pickup_by").Updates(hold).Error
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoanRepository lends copies to members following policy. A checkout
+// locks the copy and the member while it checks them, so two desks
+// can't lend the same copy or push a member over the loan limit at
+// the same time.
+type LoanRepository struct {
+	db     *gorm.DB
+	policy config.Circulation
+}
+
+var loanListSpec = listSpec{
+	table: "loans",
+	filters: merge(map[string]filterFunc{
+		"returned":      setFilter("loans.returned_at"),
+		"due_after":     timeFilter("loans.due_at", ">"),
+		"due_before":    timeFilter("loans.due_at", "<"),
+		"loaned_after":  timeFilter("loans.loaned_at", ">"),
+		"loaned_before": timeFilter("loans.loaned_at", "<"),
+	}, timestampFilters("loans")),
+	sorts: map[string]string{
+		"id":          "loans.id",
+		"loaned_at":   "loans.loaned_at",
+		"due_at":      "loans.due_at",
+		"returned_at": "loans.returned_at",
+		"created_at":  "loans.created_at",
+		"updated_at":  "loans.updated_at",
+	},
+}
+
+func NewLoanRepository(db *gorm.DB, policy config.Circulation) *LoanRepository {
+	return &LoanRepository{db: db, policy: policy}
+}
+
+// Checkout lends the copy to the member, the loan is due after the
+// policy's loan period. The copy must be available or held for the
+// member, the member must be able to borrow and be below the loan
+// limit. It fulfils the member's hold on the book.
+func (lr LoanRepository) Checkout(ctx context.Context, checkout *models.Checkout) (loan *models.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.Checkout")
+	defer span.End()
+
+	err = lr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := tx.NowFunc()
+
+		item, err := lockCopyByID(tx, checkout.CopyID)
+		if err != nil {
+			return err
+		}
+		switch item.Status {
+		case models.CopyAvailable:
+		case models.CopyOnHold:
+			var hold models.Hold
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("copy_id = ? and status = ?", item.ID, models.HoldReady).Take(&hold).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCopyUnavailable
+			}
+			if err != nil {
+				return err
+			}
+			if hold.MemberID != checkout.MemberID {
+				return ErrCopyOnHold
+			}
+		default:
+			return ErrCopyUnavailable
+		}
+
+		member, err := lockMember(tx, checkout.MemberID, 0)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !member.CanBorrow(now) {
+			return ErrMemberInactive
+		}
+
+		var open int64
+		err = tx.Model(&models.Loan{}).Where("member_id = ? and returned_at is null", member.ID).Count(&open).Error
+		if err != nil {
+			return err
+		}
+		if open >= int64(lr.policy.LoanLimit) {
+			return ErrLoanLimitReached
+		}
+
+		loan = &models.Loan{
+			CopyID:   item.ID,
+			MemberID: member.ID,
+			LoanedAt: now,
+			DueAt:    now.Add(lr.policy.LoanPeriod),
+		}
+		if err := tx.Create(loan).Error; err != nil {
+			return err
+		}
+
+		// The member's hold on the book is served by this loan,
+		// whichever copy they got. A copy waiting for them on the hold
+		// shelf goes to the next hold in line.
+		held, err := heldElsewhere(tx, item, member.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.Hold{}).
+			Where("book_id = ? and member_id = ? and status in ?", item.BookID, member.ID, activeHolds).
+			Update("status", models.HoldFulfilled).Error
+		if err != nil {
+			return err
+		}
+
+		if err := setCopyStatus(tx, item, models.CopyOnLoan); err != nil {
+			return err
+		}
+		if held != nil {
+			_, err = assignCopy(tx, held, lr.policy)
+		}
+		return err
+	})
+
+	return loan, err
+}
+
+// Return closes the loan. Its copy goes to the first eligible hold on
+// the book, which is returned with the loan, or back on the shelf.
+func (lr LoanRepository) Return(ctx context.Context, id uint) (loan *models.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.Return")
+	defer span.End()
+
+	err = lr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return err
+		}
+		if loan.ReturnedAt != nil {
+			return ErrLoanReturned
+		}
+
+		now := tx.NowFunc()
+		loan.ReturnedAt = &now
+		if err := tx.Model(loan).Update("returned_at", now).Error; err != nil {
+			return err
+		}
+
+		// The copy may have been deleted while it was out, it's
+		// back on the shelf all the same.
+		item, err := lockCopyByID(tx.Unscoped(), loan.CopyID)
+		if err != nil {
+			return err
+		}
+		loan.AssignedHold, err = assignCopy(tx, item, lr.policy)
+		return err
+	})
+
+	return loan, err
+}
+
+// Renew extends an open loan by the policy's loan period from now.
+// The member must still be able to borrow, the loan must be within
+// the renewal limit and not overdue past the grace period, and no
+// other member may be holding the book.
+func (lr LoanRepository) Renew(ctx context.Context, id uint) (loan *models.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.Renew")
+	defer span.End()
+
+	err = lr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return err
+		}
+		if loan.ReturnedAt != nil {
+			return ErrLoanReturned
+		}
+
+		now := tx.NowFunc()
+		var member models.Member
+		if err := tx.Unscoped().First(&member, loan.MemberID).Error; err != nil {
+			return err
+		}
+		if member.DeletedAt.Valid || !member.CanBorrow(now) {
+			return ErrMemberInactive
+		}
+		if now.After(loan.DueAt.Add(lr.policy.RenewalGrace)) {
+			return ErrLoanOverdue
+		}
+		if loan.Renewals >= lr.policy.MaxRenewals {
+			return ErrRenewalLimitReached
+		}
+
+		var item models.Copy
+		if err := tx.Unscoped().Select("id", "book_id").First(&item, loan.CopyID).Error; err != nil {
+			return err
+		}
+		// Holds are placed under a lock on the book, sharing it keeps
+		// one from slipping in meanwhile.
+		err := findBook(tx.Clauses(clause.Locking{Strength: "SHARE"}), item.BookID)
+		if err != nil && !errors.Is(err, ErrBookNotFound) {
+			return err
+		}
+
+		var holds int64
+		err = tx.Model(&models.Hold{}).
+			Where("book_id = ? and member_id <> ? and status in ?", item.BookID, loan.MemberID, activeHolds).
+			Count(&holds).Error
+		if err != nil {
+			return err
+		}
+		if holds > 0 {
+			return ErrHoldPending
+		}
+
+		loan.DueAt = now.Add(lr.policy.LoanPeriod)
+		loan.Renewals++
+		return tx.Model(loan).Select("due_at", "renewals").Updates(loan).Error
+	})
+
+	return loan, err
+}
+
+func (lr LoanRepository) Read(ctx context.Context, id uint) (loan *models.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.Read")
+	defer span.End()
+
+	err = lr.db.WithContext(ctx).First(&loan, id).Error
+	return loan, err
+}
+
+// ReadByMember lists the loans of a member, open and returned.
+func (lr LoanRepository) ReadByMember(ctx context.Context, memberID uint, q ListQuery) (loans []*models.Loan, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.ReadByMember")
+	defer span.End()
+
+	tx := lr.db.WithContext(ctx)
+	if err := tx.Select("id").First(&models.Member{}, memberID).Error; err != nil {
+		return nil, page, err
+	}
+
+	base := tx.Model(&models.Loan{}).Where("loans.member_id = ?", memberID).Session(&gorm.Session{})
+	return paginate(base, loanListSpec, q, loanCursor)
+}
+
+// ReadByCopy lists the loans of a copy of the book, open and
+// returned. A missing book is reported as ErrBookNotFound.
+func (lr LoanRepository) ReadByCopy(ctx context.Context, bookID, copyID uint, q ListQuery) (loans []*models.Loan, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "LoanRepository.ReadByCopy")
+	defer span.End()
+
+	tx := lr.db.WithContext(ctx)
+	if err := findBook(tx, bookID); err != nil {
+		return nil, page, err
+	}
+	if err := tx.Select("id").Where("book_id = ?", bookID).First(&models.Copy{}, copyID).Error; err != nil {
+		return nil, page, err
+	}
+
+	base := tx.Model(&models.Loan{}).Where("loans.copy_id = ?", copyID).Session(&gorm.Session{})
+	return paginate(base, loanListSpec, q, loanCursor)
+}
+
+func loanCursor(loan *models.Loan) Cursor {
+	return Cursor{CreatedAt: loan.CreatedAt, ID: loan.ID}
+}
+
+func lockCopyByID(tx *gorm.DB, id uint) (*models.Copy, error) {
+	var item models.Copy
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCopyNotFound
+	}
+	return &item, err
+}
+
+// setCopyStatus changes the status of a locked copy and bumps its
+// version, so edits based on the old status fail.
+func setCopyStatus(tx *gorm.DB, item *models.Copy, status string) error {
+	item.Status = status
+	item.Version++
+	return tx.Model(item).Select("status", "version").Updates(item).Error
+}
+
+// heldElsewhere locks the copy of the book other than item that is
+// ready for the member, if there is one.
+func heldElsewhere(tx *gorm.DB, item *models.Copy, memberID uint) (*models.Copy, error) {
+	var hold models.Hold
+	err := tx.Where("book_id = ? and member_id = ? and status = ? and copy_id <> ?",
+		item.BookID, memberID, models.HoldReady, item.ID).Take(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Copies are locked before their holds, so the hold is read again
+	// once its copy is locked.
+	held, err := lockCopyByID(tx.Unscoped(), *hold.CopyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, hold.ID).Error; err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldReady || hold.CopyID == nil || *hold.CopyID != held.ID {
+		return nil, nil
+	}
+	return held, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MemberRepository struct {
+	db *gorm.DB
+}
+
+var memberListSpec = listSpec{
+	table: "members",
+	filters: merge(map[string]filterFunc{
+		"q":              anyFilter(equalFilter("members.card_number"), containsFilter("members.name")),
+		"card_number":    equalFilter("members.card_number"),
+		"name":           equalFilter("members.name"),
+		"name~":          containsFilter("members.name"),
+		"type":           equalFilter("members.type"),
+		"status":         equalFilter("members.status"),
+		"expires_after":  timeFilter("members.expires_at", ">"),
+		"expires_before": timeFilter("members.expires_at", "<"),
+	}, timestampFilters("members")),
+	sorts: map[string]string{
+		"id":          "members.id",
+		"card_number": "members.card_number",
+		"name":        "members.name",
+		"expires_at":  "members.expires_at",
+		"created_at":  "members.created_at",
+		"updated_at":  "members.updated_at",
+	},
+}
+
+func NewMemberRepository(db *gorm.DB) *MemberRepository {
+	return &MemberRepository{db: db}
+}
+
+func (mr MemberRepository) Create(ctx context.Context, member *models.Member) error {
+	ctx, span := tracing.Start(ctx, "MemberRepository.Create")
+	defer span.End()
+
+	// Expiry filters compare in UTC.
+	member.ExpiresAt = member.ExpiresAt.UTC()
+	return mr.db.WithContext(ctx).Create(member).Error
+}
+
+func (mr MemberRepository) Read(ctx context.Context, id uint) (member *models.Member, err error) {
+	ctx, span := tracing.Start(ctx, "MemberRepository.Read")
+	defer span.End()
+
+	err = mr.db.WithContext(ctx).First(&member, id).Error
+	return member, err
+}
+
+func (mr MemberRepository) ReadAll(ctx context.Context, q ListQuery) (members []*models.Member, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "MemberRepository.ReadAll")
+	defer span.End()
+
+	base := mr.db.WithContext(ctx).Model(&models.Member{}).Session(&gorm.Session{})
+	return paginate(base, memberListSpec, q, memberCursor)
+}
+
+// Update replaces the stored member with newMember, including zero
+// values. A non-zero version must match the stored one, otherwise
+// ErrVersionMismatch is returned.
+func (mr MemberRepository) Update(ctx context.Context, id, version uint, newMember *models.Member) error {
+	ctx, span := tracing.Start(ctx, "MemberRepository.Update")
+	defer span.End()
+
+	return mr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := lockMember(tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		return replaceMember(tx, member, newMember)
+	})
+}
+
+// Patch locks the stored member, lets patch modify a copy of it and
+// saves the result like Update. It returns the member as stored.
+func (mr MemberRepository) Patch(ctx context.Context, id, version uint, patch func(member *models.Member) error) (patched *models.Member, err error) {
+	ctx, span := tracing.Start(ctx, "MemberRepository.Patch")
+	defer span.End()
+
+	err = mr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := lockMember(tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		newMember := *member
+		if err := patch(&newMember); err != nil {
+			return err
+		}
+
+		if err := replaceMember(tx, member, &newMember); err != nil {
+			return err
+		}
+
+		return tx.First(&patched, id).Error
+	})
+
+	return patched, err
+}
+
+func (mr MemberRepository) Delete(ctx context.Context, id, version uint) error {
+	ctx, span := tracing.Start(ctx, "MemberRepository.Delete")
+	defer span.End()
+
+	return mr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := lockMember(tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(member).Error
+	})
+}
+
+func memberCursor(member *models.Member) Cursor {
+	return Cursor{CreatedAt: member.CreatedAt, ID: member.ID}
+}
+
+func lockMember(tx *gorm.DB, id, version uint) (*models.Member, error) {
+	var member models.Member
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&member, id).Error; err != nil {
+		return nil, err
+	}
+
+	if version != 0 && member.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return &member, nil
+}
+
+var memberColumns = []string{"card_number", "name", "email", "phone", "address", "type", "status", "expires_at", "version"}
+
+func replaceMember(tx *gorm.DB, member, newMember *models.Member) error {
+	newMember.ID = member.ID
+	newMember.CreatedAt = member.CreatedAt
+	newMember.Version = member.Version + 1
+	newMember.ExpiresAt = newMember.ExpiresAt.UTC()
+	return tx.Model(member).Select(memberColumns).Updates(newMember).Error
+}
@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"gorm.io/gorm"
+)
+
+// MemoryDB keeps books, authors and the links between them in memory.
+// The stores built on it behave like the Postgres repositories, which
+// the conformance tests check. It's safe for concurrent use.
+type MemoryDB struct {
+	mu        sync.RWMutex
+	books     map[uint]models.Book
+	authors   map[uint]models.Author
+	links     map[memoryLink]bool
+	bookSeq   uint
+	authorSeq uint
+}
+
+type memoryLink struct {
+	bookID   uint
+	authorID uint
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		books:   map[uint]models.Book{},
+		authors: map[uint]models.Author{},
+		links:   map[memoryLink]bool{},
+	}
+}
+
+// The methods below expect the caller to hold mu. Records are stored
+// without their associations and copied on the way in and out.
+
+func (db *MemoryDB) insertBook(book *models.Book) {
+	now := time.Now()
+	if book.ID == 0 {
+		db.bookSeq++
+		book.ID = db.bookSeq
+	}
+	db.bookSeq = max(db.bookSeq, book.ID)
+	if book.CreatedAt.IsZero() {
+		book.CreatedAt = now
+	}
+	if book.UpdatedAt.IsZero() {
+		book.UpdatedAt = now
+	}
+	if book.Version == 0 {
+		book.Version = 1
+	}
+
+	stored := *book
+	stored.Authors = nil
+	db.books[book.ID] = stored
+}
+
+func (db *MemoryDB) insertAuthor(author *models.Author) {
+	now := time.Now()
+	if author.ID == 0 {
+		db.authorSeq++
+		author.ID = db.authorSeq
+	}
+	db.authorSeq = max(db.authorSeq, author.ID)
+	if author.CreatedAt.IsZero() {
+		author.CreatedAt = now
+	}
+	if author.UpdatedAt.IsZero() {
+		author.UpdatedAt = now
+	}
+	if author.Version == 0 {
+		author.Version = 1
+	}
+
+	stored := *author
+	stored.Books = nil
+	db.authors[author.ID] = stored
+}
+
+// linkAuthors links the book to authors, creating the new ones.
+// Existing authors are left as they are, like an upsert that does
+// nothing on conflict.
+func (db *MemoryDB) linkAuthors(bookID uint, authors []*models.Author) {
+	for _, author := range authors {
+		if author == nil {
+			continue
+		}
+		if _, ok := db.authors[author.ID]; !ok {
+			db.insertAuthor(author)
+		}
+		db.links[memoryLink{bookID: bookID, authorID: author.ID}] = true
+	}
+}
+
+func (db *MemoryDB) linkBooks(authorID uint, books []*models.Book) {
+	for _, book := range books {
+		if book == nil {
+			continue
+		}
+		if _, ok := db.books[book.ID]; !ok {
+			db.insertBook(book)
+		}
+		db.links[memoryLink{bookID: book.ID, authorID: authorID}] = true
+	}
+}
+
+func (db *MemoryDB) unlink(match func(link memoryLink) bool) {
+	for link := range db.links {
+		if match(link) {
+			delete(db.links, link)
+		}
+	}
+}
+
+func (db *MemoryDB) authorIDs(bookID uint) (ids []uint) {
+	for link := range db.links {
+		if link.bookID == bookID {
+			ids = append(ids, link.authorID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (db *MemoryDB) bookIDs(authorID uint) (ids []uint) {
+	for link := range db.links {
+		if link.authorID == authorID {
+			ids = append(ids, link.bookID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (db *MemoryDB) readBook(id uint, preloads ...string) (*models.Book, error) {
+	stored, ok := db.books[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	book := stored
+	return &book, db.preloadBook(&book, preloads)
+}
+
+func (db *MemoryDB) readAuthor(id uint, preloads ...string) (*models.Author, error) {
+	stored, ok := db.authors[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	author := stored
+	return &author, db.preloadAuthor(&author, preloads)
+}
+
+// preloadBook loads the associations named by preloads, e.g. "Authors"
+// or "Authors.Books", like gorm's Preload.
+func (db *MemoryDB) preloadBook(book *models.Book, preloads []string) error {
+	nested, ok, err := splitPreloads(preloads, "Authors")
+	if !ok || err != nil {
+		return err
+	}
+
+	book.Authors = []*models.Author{}
+	for _, id := range db.authorIDs(book.ID) {
+		author, err := db.readAuthor(id, nested...)
+		if err != nil {
+			return err
+		}
+		book.Authors = append(book.Authors, author)
+	}
+	return nil
+}
+
+func (db *MemoryDB) preloadAuthor(author *models.Author, preloads []string) error {
+	nested, ok, err := splitPreloads(preloads, "Books")
+	if !ok || err != nil {
+		return err
+	}
+
+	author.Books = []*models.Book{}
+	for _, id := range db.bookIDs(author.ID) {
+		book, err := db.readBook(id, nested...)
+		if err != nil {
+			return err
+		}
+		author.Books = append(author.Books, book)
+	}
+	return nil
+}
+
+// splitPreloads checks that preloads only name the association and
+// returns what's preloaded below it.
+func splitPreloads(preloads []string, association string) (nested []string, ok bool, err error) {
+	for _, preload := range preloads {
+		name, rest, _ := strings.Cut(preload, ".")
+		if name != association {
+			return nil, false, fmt.Errorf("%s: unsupported relations", name)
+		}
+		ok = true
+		if rest != "" {
+			nested = append(nested, rest)
+		}
+	}
+	return nested, ok, nil
+}
+
+// memoryFilter parses a filter value into a predicate. The database is
+// passed for filters on links.
+type memoryFilter[T any] func(db *MemoryDB, value string) (func(item *T) bool, error)
+
+// memoryListSpec mirrors listSpec for the in-memory stores.
+type memoryListSpec[T any] struct {
+	filters map[string]memoryFilter[T]
+	sorts   map[string]func(a, b *T) int
+	key     func(*T) Cursor
+}
+
+// paginate selects a page of items like the SQL paginate does.
+func (s memoryListSpec[T]) paginate(db *MemoryDB, items []*T, q ListQuery) (_ []*T, page Page, err error) {
+	if q.Keyset() && len(q.Sort) > 0 {
+		return nil, page, &InvalidQueryError{Param: "sort", Reason: "not supported with cursor pagination"}
+	}
+
+	for param, value := range q.Filters {
+		filter, ok := s.filters[param]
+		if !ok {
+			return nil, page, &InvalidQueryError{Param: param, Reason: "unknown filter"}
+		}
+
+		match, err := filter(db, value)
+		if err != nil {
+			return nil, page, &InvalidQueryError{Param: param, Reason: err.Error()}
+		}
+		items = slices.DeleteFunc(items, func(item *T) bool { return !match(item) })
+	}
+	page.Total = int64(len(items))
+
+	byKey := func(a, b *T) int { return compareCursors(s.key(a), s.key(b)) }
+	backward := q.Cursor != nil && q.Cursor.Backward
+	switch {
+	case !q.Keyset() && len(q.Sort) > 0:
+		order, err := s.order(q.Sort)
+		if err != nil {
+			return nil, page, err
+		}
+		slices.SortStableFunc(items, order)
+		items = items[min((q.Page-1)*q.Limit, len(items)):]
+	case !q.Keyset():
+		slices.SortFunc(items, byKey)
+		items = items[min((q.Page-1)*q.Limit, len(items)):]
+	case q.Cursor == nil:
+		slices.SortFunc(items, byKey)
+	case backward:
+		items = slices.DeleteFunc(items, func(item *T) bool { return compareCursors(s.key(item), *q.Cursor) >= 0 })
+		slices.SortFunc(items, func(a, b *T) int { return byKey(b, a) })
+	default:
+		items = slices.DeleteFunc(items, func(item *T) bool { return compareCursors(s.key(item), *q.Cursor) <= 0 })
+		slices.SortFunc(items, byKey)
+	}
+
+	more := len(items) > q.Limit
+	if more {
+		items = items[:q.Limit]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+
+	if !q.Keyset() || len(items) == 0 {
+		return items, page, nil
+	}
+
+	if more || backward {
+		next := s.key(items[len(items)-1])
+		page.Next = &next
+	}
+	if q.Cursor != nil && (more || !backward) {
+		prev := s.key(items[0])
+		prev.Backward = true
+		page.Prev = &prev
+	}
+
+	return items, page, nil
+}
+
+func (s memoryListSpec[T]) order(sort []string) (func(a, b *T) int, error) {
+	var compares []func(a, b *T) int
+	for _, key := range sort {
+		field, desc := strings.CutPrefix(key, "-")
+		compare, ok := s.sorts[field]
+		if !ok {
+			return nil, &InvalidQueryError{Param: "sort", Reason: fmt.Sprintf("unknown field %q", field)}
+		}
+
+		if desc {
+			asc := compare
+			compare = func(a, b *T) int { return asc(b, a) }
+		}
+		compares = append(compares, compare)
+	}
+
+	return func(a, b *T) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(s.key(a).ID, s.key(b).ID)
+	}, nil
+}
+
+func compareCursors(a, b Cursor) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func equalMatch[T any](field func(*T) string) memoryFilter[T] {
+	return func(_ *MemoryDB, value string) (func(*T) bool, error) {
+		return func(item *T) bool { return field(item) == value }, nil
+	}
+}
+
+func containsMatch[T any](field func(*T) string) memoryFilter[T] {
+	return func(_ *MemoryDB, value string) (func(*T) bool, error) {
+		value = strings.ToLower(value)
+		return func(item *T) bool { return strings.Contains(strings.ToLower(field(item)), value) }, nil
+	}
+}
+
+// intMatch keeps the items whose field compares to the value as op,
+// which is ">=" or "<=".
+func intMatch[T any](field func(*T) int, op string) memoryFilter[T] {
+	return func(_ *MemoryDB, value string) (func(*T) bool, error) {
+		n, err := parseInt(value)
+		if err != nil {
+			return nil, err
+		}
+		want := map[string][]int{">=": {0, 1}, "<=": {-1, 0}}[op]
+		return func(item *T) bool { return slices.Contains(want, cmp.Compare(field(item), n)) }, nil
+	}
+}
+
+// timeMatch keeps the items whose field is after (">") or before ("<")
+// the value.
+func timeMatch[T any](field func(*T) time.Time, op string) memoryFilter[T] {
+	return func(_ *MemoryDB, value string) (func(*T) bool, error) {
+		t, err := parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == ">" {
+			return func(item *T) bool { return field(item).After(t) }, nil
+		}
+		return func(item *T) bool { return field(item).Before(t) }, nil
+	}
+}
+
+// linkMatch keeps the items linked to the given id.
+func linkMatch[T any](linked func(db *MemoryDB, item *T, id uint) bool) memoryFilter[T] {
+	return func(db *MemoryDB, value string) (func(*T) bool, error) {
+		id, err := parseID(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(item *T) bool { return linked(db, item, id) }, nil
+	}
+}
+
+func timestampMatches[T any](createdAt, updatedAt func(*T) time.Time) map[string]memoryFilter[T] {
+	return map[string]memoryFilter[T]{
+		"created_after":  timeMatch(createdAt, ">"),
+		"created_before": timeMatch(createdAt, "<"),
+		"updated_after":  timeMatch(updatedAt, ">"),
+		"updated_before": timeMatch(updatedAt, "<"),
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/models"
+)
+
+// MemoryAuthorStore is the in-memory AuthorStore.
+type MemoryAuthorStore struct {
+	db *MemoryDB
+}
+
+var memoryAuthorListSpec = memoryListSpec[models.Author]{
+	filters: merge(map[string]memoryFilter[models.Author]{
+		"name":  equalMatch(func(a *models.Author) string { return a.Name }),
+		"name~": containsMatch(func(a *models.Author) string { return a.Name }),
+		"book_id": linkMatch(func(db *MemoryDB, a *models.Author, id uint) bool {
+			return db.links[memoryLink{bookID: id, authorID: a.ID}]
+		}),
+	}, timestampMatches(
+		func(a *models.Author) time.Time { return a.CreatedAt },
+		func(a *models.Author) time.Time { return a.UpdatedAt },
+	)),
+	sorts: map[string]func(a, b *models.Author) int{
+		"id":         func(a, b *models.Author) int { return cmp.Compare(a.ID, b.ID) },
+		"name":       func(a, b *models.Author) int { return strings.Compare(a.Name, b.Name) },
+		"created_at": func(a, b *models.Author) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		"updated_at": func(a, b *models.Author) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	},
+	key: authorCursor,
+}
+
+func NewMemoryAuthorStore(db *MemoryDB) *MemoryAuthorStore {
+	return &MemoryAuthorStore{db: db}
+}
+
+func (as MemoryAuthorStore) Create(ctx context.Context, author *models.Author) error {
+	as.db.mu.Lock()
+	defer as.db.mu.Unlock()
+
+	as.db.insertAuthor(author)
+	as.db.linkBooks(author.ID, author.Books)
+	return nil
+}
+
+func (as MemoryAuthorStore) Read(ctx context.Context, id uint, preloads ...string) (*models.Author, error) {
+	as.db.mu.RLock()
+	defer as.db.mu.RUnlock()
+
+	return as.db.readAuthor(id, preloads...)
+}
+
+func (as MemoryAuthorStore) ReadAll(ctx context.Context, q ListQuery) ([]*models.Author, Page, error) {
+	as.db.mu.RLock()
+	defer as.db.mu.RUnlock()
+
+	authors := make([]*models.Author, 0, len(as.db.authors))
+	for _, stored := range as.db.authors {
+		author := stored
+		authors = append(authors, &author)
+	}
+
+	authors, page, err := memoryAuthorListSpec.paginate(as.db, authors, q)
+	if err != nil {
+		return nil, page, err
+	}
+	for _, author := range authors {
+		if err := as.db.preloadAuthor(author, q.Preloads); err != nil {
+			return nil, page, err
+		}
+	}
+	return authors, page, nil
+}
+
+// Update replaces the stored author with newAuthor like
+// AuthorRepository.Update.
+func (as MemoryAuthorStore) Update(ctx context.Context, id, version uint, newAuthor *models.Author) error {
+	as.db.mu.Lock()
+	defer as.db.mu.Unlock()
+
+	author, err := as.db.lockAuthor(id, version)
+	if err != nil {
+		return err
+	}
+
+	as.db.replaceAuthor(author, newAuthor)
+	return nil
+}
+
+// Patch lets patch modify a copy of the stored author and saves the
+// result like Update, see MemoryBookStore.Patch.
+func (as MemoryAuthorStore) Patch(ctx context.Context, id, version uint, patch func(author *models.Author) error) (*models.Author, error) {
+	for {
+		as.db.mu.RLock()
+		author, err := as.db.lockAuthor(id, version, "Books")
+		as.db.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		newAuthor := *author
+		if err := patch(&newAuthor); err != nil {
+			return nil, err
+		}
+
+		as.db.mu.Lock()
+		if stored, ok := as.db.authors[id]; !ok || stored.Version != author.Version {
+			as.db.mu.Unlock()
+			continue
+		}
+		as.db.replaceAuthor(author, &newAuthor)
+		patched, err := as.db.readAuthor(id, "Books")
+		as.db.mu.Unlock()
+
+		return patched, err
+	}
+}
+
+func (as MemoryAuthorStore) Delete(ctx context.Context, id, version uint) error {
+	as.db.mu.Lock()
+	defer as.db.mu.Unlock()
+
+	if _, err := as.db.lockAuthor(id, version); err != nil {
+		return err
+	}
+
+	delete(as.db.authors, id)
+	as.db.unlink(func(link memoryLink) bool { return link.authorID == id })
+	return nil
+}
+
+func (db *MemoryDB) lockAuthor(id, version uint, preloads ...string) (*models.Author, error) {
+	author, err := db.readAuthor(id, preloads...)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && author.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return author, nil
+}
+
+func (db *MemoryDB) replaceAuthor(author, newAuthor *models.Author) {
+	newAuthor.ID = author.ID
+	newAuthor.Version = author.Version + 1
+
+	stored := db.authors[author.ID]
+	stored.Name = newAuthor.Name
+	stored.Version = newAuthor.Version
+	stored.UpdatedAt = time.Now()
+	db.authors[author.ID] = stored
+
+	db.unlink(func(link memoryLink) bool { return link.authorID == author.ID })
+	db.linkBooks(author.ID, newAuthor.Books)
+}
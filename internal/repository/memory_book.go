@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/models"
+)
+
+// MemoryBookStore is the in-memory BookStore.
+type MemoryBookStore struct {
+	db *MemoryDB
+}
+
+var memoryBookListSpec = memoryListSpec[models.Book]{
+	filters: merge(map[string]memoryFilter[models.Book]{
+		"title":     equalMatch(func(b *models.Book) string { return b.Title }),
+		"title~":    containsMatch(func(b *models.Book) string { return b.Title }),
+		"pages_min": intMatch(func(b *models.Book) int { return b.Pages }, ">="),
+		"pages_max": intMatch(func(b *models.Book) int { return b.Pages }, "<="),
+		"author_id": linkMatch(func(db *MemoryDB, b *models.Book, id uint) bool {
+			return db.links[memoryLink{bookID: b.ID, authorID: id}]
+		}),
+	}, timestampMatches(
+		func(b *models.Book) time.Time { return b.CreatedAt },
+		func(b *models.Book) time.Time { return b.UpdatedAt },
+	)),
+	sorts: map[string]func(a, b *models.Book) int{
+		"id":         func(a, b *models.Book) int { return cmp.Compare(a.ID, b.ID) },
+		"title":      func(a, b *models.Book) int { return strings.Compare(a.Title, b.Title) },
+		"pages":      func(a, b *models.Book) int { return cmp.Compare(a.Pages, b.Pages) },
+		"created_at": func(a, b *models.Book) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		"updated_at": func(a, b *models.Book) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	},
+	key: bookCursor,
+}
+
+func NewMemoryBookStore(db *MemoryDB) *MemoryBookStore {
+	return &MemoryBookStore{db: db}
+}
+
+func (bs MemoryBookStore) Create(ctx context.Context, book *models.Book) error {
+	bs.db.mu.Lock()
+	defer bs.db.mu.Unlock()
+
+	bs.db.insertBook(book)
+	bs.db.linkAuthors(book.ID, book.Authors)
+	return nil
+}
+
+func (bs MemoryBookStore) Read(ctx context.Context, id uint, preloads ...string) (*models.Book, error) {
+	bs.db.mu.RLock()
+	defer bs.db.mu.RUnlock()
+
+	return bs.db.readBook(id, preloads...)
+}
+
+func (bs MemoryBookStore) ReadAll(ctx context.Context, q ListQuery) ([]*models.Book, Page, error) {
+	bs.db.mu.RLock()
+	defer bs.db.mu.RUnlock()
+
+	books := make([]*models.Book, 0, len(bs.db.books))
+	for _, stored := range bs.db.books {
+		book := stored
+		books = append(books, &book)
+	}
+
+	books, page, err := memoryBookListSpec.paginate(bs.db, books, q)
+	if err != nil {
+		return nil, page, err
+	}
+	for _, book := range books {
+		if err := bs.db.preloadBook(book, q.Preloads); err != nil {
+			return nil, page, err
+		}
+	}
+	return books, page, nil
+}
+
+// Update replaces the stored book with newBook like
+// BookRepository.Update.
+func (bs MemoryBookStore) Update(ctx context.Context, id, version uint, newBook *models.Book) error {
+	bs.db.mu.Lock()
+	defer bs.db.mu.Unlock()
+
+	book, err := bs.db.lockBook(id, version)
+	if err != nil {
+		return err
+	}
+
+	bs.db.replaceBook(book, newBook)
+	return nil
+}
+
+// Patch lets patch modify a copy of the stored book and saves the
+// result like Update. patch runs without the lock held, so it may use
+// the store, and is run again if the book changes in the meantime.
+func (bs MemoryBookStore) Patch(ctx context.Context, id, version uint, patch func(book *models.Book) error) (*models.Book, error) {
+	for {
+		bs.db.mu.RLock()
+		book, err := bs.db.lockBook(id, version, "Authors")
+		bs.db.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		newBook := *book
+		if err := patch(&newBook); err != nil {
+			return nil, err
+		}
+
+		bs.db.mu.Lock()
+		if stored, ok := bs.db.books[id]; !ok || stored.Version != book.Version {
+			bs.db.mu.Unlock()
+			continue
+		}
+		bs.db.replaceBook(book, &newBook)
+		patched, err := bs.db.readBook(id, "Authors")
+		bs.db.mu.Unlock()
+
+		return patched, err
+	}
+}
+
+func (bs MemoryBookStore) Delete(ctx context.Context, id, version uint) error {
+	bs.db.mu.Lock()
+	defer bs.db.mu.Unlock()
+
+	if _, err := bs.db.lockBook(id, version); err != nil {
+		return err
+	}
+
+	delete(bs.db.books, id)
+	bs.db.unlink(func(link memoryLink) bool { return link.bookID == id })
+	return nil
+}
+
+func (bs MemoryBookStore) MissingAuthors(ctx context.Context, ids []uint) (missing []uint, err error) {
+	bs.db.mu.RLock()
+	defer bs.db.mu.RUnlock()
+
+	for _, id := range ids {
+		if _, ok := bs.db.authors[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func (db *MemoryDB) lockBook(id, version uint, preloads ...string) (*models.Book, error) {
+	book, err := db.readBook(id, preloads...)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && book.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return book, nil
+}
+
+func (db *MemoryDB) replaceBook(book, newBook *models.Book) {
+	newBook.ID = book.ID
+	newBook.Version = book.Version + 1
+
+	stored := db.books[book.ID]
+	stored.Title = newBook.Title
+	stored.Pages = newBook.Pages
+	stored.Version = newBook.Version
+	stored.UpdatedAt = time.Now()
+	db.books[book.ID] = stored
+
+	db.unlink(func(link memoryLink) bool { return link.bookID == book.ID })
+	db.linkAuthors(book.ID, newBook.Authors)
+}
+
+// Availability reports no copies, the memory store only knows the
+// catalog.
+func (bs MemoryBookStore) Availability(ctx context.Context, id uint) (*models.Availability, error) {
+	return &models.Availability{}, nil
+}
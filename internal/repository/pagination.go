@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row in the (created_at, id) keyset ordering.
+// Backward cursors select the rows that come before it.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// ListQuery describes one page of a listing. Page > 0 selects offset
+// pagination, otherwise the listing is keyset-paginated starting
+// after Cursor (or from the beginning if Cursor is nil). Filters maps
+// whitelisted query parameters to their values, Sort lists sort keys,
+// "-" prefixed for descending order. Preloads names the associations
+// to load with every item.
+type ListQuery struct {
+	Limit    int
+	Page     int
+	Cursor   *Cursor
+	Filters  map[string]string
+	Sort     []string
+	Preloads []string
+}
+
+func (q ListQuery) Keyset() bool {
+	return q.Page == 0
+}
+
+// Page holds listing metadata. Next and Prev are only set in keyset mode.
+type Page struct {
+	Total int64
+	Next  *Cursor
+	Prev  *Cursor
+}
+
+func paginate[T any](base *gorm.DB, spec listSpec, q ListQuery, key func(*T) Cursor) (items []*T, page Page, err error) {
+	if q.Keyset() && len(q.Sort) > 0 {
+		return nil, page, &InvalidQueryError{Param: "sort", Reason: "not supported with cursor pagination"}
+	}
+
+	if base, err = spec.apply(base, q); err != nil {
+		return nil, page, err
+	}
+	base = base.Session(&gorm.Session{})
+
+	if err = base.Count(&page.Total).Error; err != nil {
+		return nil, page, err
+	}
+
+	tx := base
+	for _, preload := range q.Preloads {
+		tx = tx.Preload(preload)
+	}
+
+	createdAt, id := spec.table+".created_at", spec.table+".id"
+	backward := q.Cursor != nil && q.Cursor.Backward
+	switch {
+	case !q.Keyset() && len(q.Sort) > 0:
+		if tx, err = spec.order(tx, q.Sort); err != nil {
+			return nil, page, err
+		}
+		tx = tx.Offset((q.Page - 1) * q.Limit)
+	case !q.Keyset():
+		tx = tx.Order(createdAt).Order(id).Offset((q.Page - 1) * q.Limit)
+	case q.Cursor == nil:
+		tx = tx.Order(createdAt).Order(id)
+	case backward:
+		tx = tx.Where("("+createdAt+", "+id+") < (?, ?)", q.Cursor.CreatedAt.UTC(), q.Cursor.ID).
+			Order(createdAt + " desc").Order(id + " desc")
+	default:
+		tx = tx.Where("("+createdAt+", "+id+") > (?, ?)", q.Cursor.CreatedAt.UTC(), q.Cursor.ID).
+			Order(createdAt).Order(id)
+	}
+
+	if err = tx.Limit(q.Limit + 1).Find(&items).Error; err != nil {
+		return nil, page, err
+	}
+
+	more := len(items) > q.Limit
+	if more {
+		items = items[:q.Limit]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+
+	if !q.Keyset() || len(items) == 0 {
+		return items, page, nil
+	}
+
+	if more || backward {
+		next := key(items[len(items)-1])
+		page.Next = &next
+	}
+	if q.Cursor != nil && (more || !backward) {
+		prev := key(items[0])
+		prev.Backward = true
+		page.Prev = &prev
+	}
+
+	return items, page, nil
+}
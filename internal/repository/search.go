@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+)
+
+// searchDialect holds the search queries of a database. sources maps a
+// result type to the query over its table, every source exposes the
+// same columns so they can be combined with union. query turns the
+// search text into the @q argument of the sources, an empty result
+// matches nothing.
+type searchDialect struct {
+	sources map[string]string
+	query   func(text string) string
+}
+
+var searchDialects = map[string]searchDialect{
+	"postgres": {
+		sources: map[string]string{
+			models.SearchTypeBook: `
+				select 'book' as type, id, title,
+					ts_rank(search_vector, query) as score,
+					ts_headline('simple', title, query, 'StartSel=<mark>, StopSel=</mark>') as snippet
+				from books, websearch_to_tsquery('simple', @q) query
+				where deleted_at is null and search_vector @@ query`,
+			models.SearchTypeAuthor: `
+				select 'author' as type, id, name as title,
+					ts_rank(search_vector, query) as score,
+					ts_headline('simple', name, query, 'StartSel=<mark>, StopSel=</mark>') as snippet
+				from authors, websearch_to_tsquery('simple', @q) query
+				where deleted_at is null and search_vector @@ query`,
+		},
+		query: func(text string) string { return text },
+	},
+	"sqlite": {
+		sources: map[string]string{
+			models.SearchTypeBook: `
+				select 'book' as type, books.id, books.title,
+					-bm25(books_search) as score,
+					highlight(books_search, 0, '<mark>', '</mark>') as snippet
+				from books_search join books on books.id = books_search.rowid
+				where books_search match @q and books.deleted_at is null`,
+			models.SearchTypeAuthor: `
+				select 'author' as type, authors.id, authors.name as title,
+					-bm25(authors_search) as score,
+					highlight(authors_search, 0, '<mark>', '</mark>') as snippet
+				from authors_search join authors on authors.id = authors_search.rowid
+				where authors_search match @q and authors.deleted_at is null`,
+		},
+		query: ftsQuery,
+	},
+}
+
+type SearchRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// Search runs a ranked full-text search over the given result types
+// (all of them if types is empty). Only offset pagination is supported.
+func (sr SearchRepository) Search(ctx context.Context, text string, types []string, q ListQuery) (results []*models.SearchResult, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "SearchRepository.Search")
+	defer span.End()
+
+	if len(types) == 0 {
+		types = []string{models.SearchTypeBook, models.SearchTypeAuthor}
+	}
+
+	dialect := searchDialects[sr.db.Dialector.Name()]
+	var sources []string
+	for i, t := range types {
+		source, ok := dialect.sources[t]
+		if !ok {
+			return nil, page, &InvalidQueryError{Param: "type", Reason: "unknown type " + t}
+		}
+		// A repeated type would list its results twice.
+		if slices.Contains(types[:i], t) {
+			continue
+		}
+		sources = append(sources, source)
+	}
+	union := strings.Join(sources, "\nunion all\n")
+
+	query := dialect.query(text)
+	if query == "" {
+		return nil, page, nil
+	}
+
+	err = sr.db.WithContext(ctx).Raw("select count(*) from ("+union+") results", sql.Named("q", query)).
+		Scan(&page.Total).Error
+	if err != nil {
+		return nil, page, err
+	}
+
+	err = sr.db.WithContext(ctx).Raw("select * from ("+union+") results order by score desc, type, id limit @limit offset @offset",
+		sql.Named("q", query), sql.Named("limit", q.Limit), sql.Named("offset", (q.Page-1)*q.Limit)).
+		Scan(&results).Error
+	return results, page, err
+}
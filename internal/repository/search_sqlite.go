@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ftsQuery translates the web search syntax accepted on Postgres by
+// websearch_to_tsquery into an FTS5 query: words must all match,
+// "quoted phrases" match as a whole, "or" between words matches either
+// and a leading "-" excludes a word. Every word is quoted, so FTS5
+// operators in the text are matched literally.
+func ftsQuery(text string) string {
+	var b strings.Builder
+	or := false
+	for _, word := range searchWords(text) {
+		negated := strings.HasPrefix(word, "-")
+		word = strings.TrimPrefix(word, "-")
+		switch {
+		case !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }):
+			continue
+		case strings.EqualFold(word, "or") && !negated:
+			or = b.Len() > 0
+			continue
+		case negated && b.Len() == 0:
+			// FTS5 can't match everything but a word.
+			continue
+		case negated:
+			b.WriteString(" NOT ")
+		case or:
+			b.WriteString(" OR ")
+		case b.Len() > 0:
+			b.WriteString(" ")
+		}
+		b.WriteString(`"` + strings.ReplaceAll(strings.Trim(word, `"`), `"`, `""`) + `"`)
+		or = false
+	}
+	return b.String()
+}
+
+// searchWords splits text on spaces, keeping quoted phrases together
+// with their quotes.
+func searchWords(text string) (words []string) {
+	var word strings.Builder
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
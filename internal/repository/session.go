@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create stores the session, which expires after ttl.
+func (sr SessionRepository) Create(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	ctx, span := tracing.Start(ctx, "SessionRepository.Create")
+	defer span.End()
+
+	session.ExpiresAt = sr.db.NowFunc().Add(ttl)
+	return sr.db.WithContext(ctx).Create(session).Error
+}
+
+// Active reports whether the session exists and hasn't expired or
+// been revoked.
+func (sr SessionRepository) Active(ctx context.Context, id string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "SessionRepository.Active")
+	defer span.End()
+
+	var n int64
+	err := sr.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? and revoked_at is null and expires_at > ?", id, sr.db.NowFunc()).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// Rotate replaces the refresh token hash of an active session and
+// extends it by ttl. It returns the session. A hash that
+// doesn't match means an old refresh token was replayed, so the
+// session is revoked.
+func (sr SessionRepository) Rotate(ctx context.Context, id, hash, newHash string, ttl time.Duration) (session *models.Session, err error) {
+	ctx, span := tracing.Start(ctx, "SessionRepository.Rotate")
+	defer span.End()
+
+	replayed := false
+	err = sr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		session, err = lockSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(session.RefreshHash), []byte(hash)) != 1 {
+			replayed = true
+			return tx.Model(session).Update("revoked_at", tx.NowFunc()).Error
+		}
+
+		return tx.Model(session).Updates(models.Session{RefreshHash: newHash, ExpiresAt: tx.NowFunc().Add(ttl)}).Error
+	})
+	if err == nil && replayed {
+		return nil, ErrSessionEnded
+	}
+	return session, err
+}
+
+// Revoke ends the session the refresh token hash belongs to.
+func (sr SessionRepository) Revoke(ctx context.Context, id, hash string) error {
+	ctx, span := tracing.Start(ctx, "SessionRepository.Revoke")
+	defer span.End()
+
+	return sr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		session, err := lockSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(session.RefreshHash), []byte(hash)) != 1 {
+			return ErrSessionEnded
+		}
+
+		return tx.Model(session).Update("revoked_at", tx.NowFunc()).Error
+	})
+}
+
+// lockSession returns the active session, ErrSessionEnded otherwise.
+func lockSession(tx *gorm.DB, id string) (*models.Session, error) {
+	var session models.Session
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? and revoked_at is null and expires_at > ?", id, tx.NowFunc()).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionEnded
+	}
+	return &session, err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/4otis/library_api_2025/internal/models"
+)
+
+// BookStore persists books and their links to authors. Missing books
+// are reported as gorm.ErrRecordNotFound by every implementation.
+type BookStore interface {
+	Create(ctx context.Context, book *models.Book) error
+	Read(ctx context.Context, id uint, preloads ...string) (*models.Book, error)
+	ReadAll(ctx context.Context, q ListQuery) ([]*models.Book, Page, error)
+	Update(ctx context.Context, id, version uint, newBook *models.Book) error
+	Patch(ctx context.Context, id, version uint, patch func(book *models.Book) error) (*models.Book, error)
+	Delete(ctx context.Context, id, version uint) error
+	MissingAuthors(ctx context.Context, ids []uint) ([]uint, error)
+	Availability(ctx context.Context, id uint) (*models.Availability, error)
+}
+
+// AuthorStore persists authors and their links to books.
+type AuthorStore interface {
+	Create(ctx context.Context, author *models.Author) error
+	Read(ctx context.Context, id uint, preloads ...string) (*models.Author, error)
+	ReadAll(ctx context.Context, q ListQuery) ([]*models.Author, Page, error)
+	Update(ctx context.Context, id, version uint, newAuthor *models.Author) error
+	Patch(ctx context.Context, id, version uint, patch func(author *models.Author) error) (*models.Author, error)
+	Delete(ctx context.Context, id, version uint) error
+}
+
+var (
+	_ BookStore   = (*BookRepository)(nil)
+	_ AuthorStore = (*AuthorRepository)(nil)
+	_ BookStore   = (*MemoryBookStore)(nil)
+	_ AuthorStore = (*MemoryAuthorStore)(nil)
+)
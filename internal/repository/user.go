@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+var userListSpec = listSpec{
+	table: "users",
+	filters: merge(map[string]filterFunc{
+		"username":  equalFilter("users.username"),
+		"username~": containsFilter("users.username"),
+		"role": func(tx *gorm.DB, value string) (*gorm.DB, error) {
+			return tx.Where("users.id in (select user_id from user_roles where role = ?)", value), nil
+		},
+	}, timestampFilters("users")),
+	sorts: map[string]string{
+		"id":         "users.id",
+		"username":   "users.username",
+		"created_at": "users.created_at",
+		"updated_at": "users.updated_at",
+	},
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Create stores the user together with its roles.
+func (ur UserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, span := tracing.Start(ctx, "UserRepository.Create")
+	defer span.End()
+
+	return ur.db.WithContext(ctx).Create(user).Error
+}
+
+func (ur UserRepository) Read(ctx context.Context, id uint) (user *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Read")
+	defer span.End()
+
+	err = ur.db.WithContext(ctx).Preload("Roles").First(&user, id).Error
+	return user, err
+}
+
+func (ur UserRepository) ReadAll(ctx context.Context, q ListQuery) (users []*models.User, page Page, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.ReadAll")
+	defer span.End()
+
+	q.Preloads = []string{"Roles"}
+	base := ur.db.WithContext(ctx).Model(&models.User{}).Session(&gorm.Session{})
+	return paginate(base, userListSpec, q, userCursor)
+}
+
+func (ur UserRepository) ReadByUsername(ctx context.Context, username string) (user *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.ReadByUsername")
+	defer span.End()
+
+	err = ur.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+// Roles returns the names of the roles assigned to the user.
+func (ur UserRepository) Roles(ctx context.Context, userID uint) (roles []string, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Roles")
+	defer span.End()
+
+	err = ur.db.WithContext(ctx).Model(&models.UserRole{}).Where("user_id = ?", userID).Pluck("role", &roles).Error
+	return roles, err
+}
+
+// SetRoles replaces the roles of the user and returns it. Taking
+// required away from its last holder fails with ErrLastRoleHolder, so
+// e.g. the last admin can't lock everyone out.
+func (ur UserRepository) SetRoles(ctx context.Context, id uint, roles []string, required string) (user *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.SetRoles")
+	defer span.End()
+
+	err = ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Roles").First(&user, id).Error; err != nil {
+			return err
+		}
+
+		held := slices.ContainsFunc(user.Roles, func(r models.UserRole) bool { return r.Role == required })
+		if held && !slices.Contains(roles, required) {
+			var holders []uint
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.UserRole{}).
+				Where("role = ? and user_id <> ?", required, id).
+				Where("user_id in (select id from users where deleted_at is null)").
+				Pluck("user_id", &holders).Error
+			if err != nil {
+				return err
+			}
+			if len(holders) == 0 {
+				return ErrLastRoleHolder
+			}
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+
+		user.Roles = make([]models.UserRole, 0, len(roles))
+		for _, role := range roles {
+			user.Roles = append(user.Roles, models.UserRole{UserID: id, Role: role})
+		}
+		if len(user.Roles) > 0 {
+			if err := tx.Create(&user.Roles).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(user).Update("updated_at", tx.NowFunc()).Error
+	})
+
+	return user, err
+}
+
+func userCursor(user *models.User) Cursor {
+	return Cursor{CreatedAt: user.CreatedAt, ID: user.ID}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+)
+
+// Server runs the HTTP server together with background workers and
+// stops them gracefully: it drains, waits for in-flight requests,
+// stops the workers newest first and then runs the stop hooks, e.g.
+// closing the database.
+type Server struct {
+	http     *http.Server
+	shutdown config.Shutdown
+	workers  []*worker
+	hooks    []hook
+	draining atomic.Bool
+}
+
+type worker struct {
+	name   string
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(cfg config.Config, handler http.Handler) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.HTTP.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+		},
+		shutdown: cfg.Shutdown,
+	}
+}
+
+// Go registers a background worker. run must return once its context
+// is cancelled. Workers start with the server.
+func (s *Server) Go(name string, run func(ctx context.Context) error) {
+	s.workers = append(s.workers, &worker{name: name, run: run})
+}
+
+// OnStop registers a hook that runs after the workers have stopped or
+// the shutdown timed out waiting for them, hooks run in the order they
+// were registered.
+func (s *Server) OnStop(name string, stop func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, hook{name: name, stop: stop})
+}
+
+// Draining reports whether the server is shutting down, it's no
+// longer ready for new traffic then.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Run listens on the configured address and serves until ctx is
+// cancelled, then shuts down.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return errors.Join(err, s.stop(context.Background()))
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	for _, w := range s.workers {
+		var workerCtx context.Context
+		workerCtx, w.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		w.done = make(chan struct{})
+
+		go func() {
+			defer close(w.done)
+			if err := w.run(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Error. Worker stopped", "worker", w.name, "error", err)
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "addr", ln.Addr().String())
+		serveErr <- s.http.Serve(ln)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		slog.Info("Shutting down", "drain_timeout", s.shutdown.DrainTimeout.String())
+		s.draining.Store(true)
+		time.Sleep(s.shutdown.DrainTimeout)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), s.shutdown.Timeout)
+	defer cancel()
+
+	if shutdownErr := s.http.Shutdown(stopCtx); shutdownErr != nil {
+		err = errors.Join(err, fmt.Errorf("http server: %w", shutdownErr))
+	}
+	err = errors.Join(err, s.stop(stopCtx))
+
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+func (s *Server) stop(ctx context.Context) (err error) {
+	s.draining.Store(true)
+
+	for i := len(s.workers) - 1; i >= 0; i-- {
+		w := s.workers[i]
+		if w.cancel == nil {
+			continue
+		}
+
+		w.cancel()
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			err = errors.Join(err, fmt.Errorf("worker %s: %w", w.name, ctx.Err()))
+		}
+	}
+
+	// Hooks run even if workers are stuck, so the database still
+	// gets closed and spans flushed.
+	for _, h := range s.hooks {
+		if hookErr := h.stop(ctx); hookErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", h.name, hookErr))
+		}
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oldSecret = "old-secret-old-secret-old-secret"
+	newSecret = "new-secret-new-secret-new-secret"
+)
+
+func newTokens(t *testing.T, keys, kid string) *auth.Tokens {
+	cfg := config.Default().Auth
+	cfg.SigningKeys = config.Secret(keys)
+	cfg.SigningKeyID = kid
+
+	tokens, err := auth.NewTokens(cfg)
+	require.NoError(t, err)
+	return tokens
+}
+
+func TestTokens(t *testing.T) {
+	t.Run("Tokens - Issue and verify", func(t *testing.T) {
+		tokens := newTokens(t, "k1:"+oldSecret, "k1")
+
+		token, expiresAt, err := tokens.Issue(42, "session")
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, time.Second)
+
+		claims, err := tokens.Verify(token)
+		require.NoError(t, err)
+		userID, err := claims.UserID()
+		require.NoError(t, err)
+		assert.Equal(t, uint(42), userID)
+		assert.Equal(t, "session", claims.SessionID)
+	})
+
+	t.Run("Tokens - Key rotation", func(t *testing.T) {
+		before := newTokens(t, "k1:"+oldSecret, "k1")
+		oldToken, _, err := before.Issue(1, "session")
+		require.NoError(t, err)
+
+		// k2 becomes active while k1 tokens are still accepted.
+		during := newTokens(t, "k1:"+oldSecret+",k2:"+newSecret, "k2")
+		_, err = during.Verify(oldToken)
+		assert.NoError(t, err)
+
+		newToken, _, err := during.Issue(1, "session")
+		require.NoError(t, err)
+
+		// Once k1 is removed its tokens are rejected.
+		after := newTokens(t, "k2:"+newSecret, "k2")
+		_, err = after.Verify(oldToken)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		_, err = after.Verify(newToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Tokens - Same kid with another secret", func(t *testing.T) {
+		token, _, err := newTokens(t, "k1:"+oldSecret, "k1").Issue(1, "session")
+		require.NoError(t, err)
+
+		_, err = newTokens(t, "k1:"+newSecret, "k1").Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("Tokens - Expired", func(t *testing.T) {
+		cfg := config.Default().Auth
+		cfg.AccessTTL = -time.Minute
+		tokens, err := auth.NewTokens(cfg)
+		require.NoError(t, err)
+
+		token, _, err := tokens.Issue(1, "session")
+		require.NoError(t, err)
+
+		_, err = tokens.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("Tokens - Tampered", func(t *testing.T) {
+		tokens := newTokens(t, "k1:"+oldSecret, "k1")
+		token, _, err := tokens.Issue(1, "session")
+		require.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		other, _, err := tokens.Issue(2, "other")
+		require.NoError(t, err)
+		parts[1] = strings.Split(other, ".")[1]
+
+		_, err = tokens.Verify(strings.Join(parts, "."))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
+
+func TestPasswords(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	require.NoError(t, err)
+
+	assert.True(t, auth.CheckPassword(hash, "correct horse"))
+	assert.False(t, auth.CheckPassword(hash, "wrong horse"))
+	assert.False(t, auth.CheckPassword("", "correct horse"))
+}
+
+func TestRefreshTokens(t *testing.T) {
+	token, hash := auth.NewRefreshToken("session")
+
+	sessionID, parsed, err := auth.ParseRefreshToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "session", sessionID)
+	assert.Equal(t, hash, parsed)
+	assert.NotContains(t, token, hash)
+
+	_, _, err = auth.ParseRefreshToken("no-secret")
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestAPIKeys(t *testing.T) {
+	id, key, hash := auth.NewAPIKey()
+	other, _, _ := auth.NewAPIKey()
+	assert.NotEqual(t, id, other)
+
+	parsedID, parsed, err := auth.ParseAPIKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsedID)
+	assert.Equal(t, hash, parsed)
+	assert.NotContains(t, key, hash)
+
+	assert.True(t, auth.ValidScope("books:write"))
+	assert.False(t, auth.ValidScope("users:manage"))
+}
@@ -0,0 +1,144 @@
+package config_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// clearEnv unsets the LIBRARY_ variables for the test, e.g. the
+// LIBRARY_DB_DRIVER the SQLite test run sets.
+func clearEnv(t *testing.T) {
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(key, "LIBRARY_") {
+			t.Setenv(key, "")
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	clearEnv(t)
+
+	t.Run("Load Config - Defaults", func(t *testing.T) {
+		cfg, rest, err := config.Load(nil)
+		require.NoError(t, err)
+		assert.Equal(t, config.Default(), cfg)
+		assert.Empty(t, rest)
+	})
+
+	t.Run("Load Config - Precedence", func(t *testing.T) {
+		path := writeConfig(t, "http:\n  addr: :8000\ndb:\n  host: file-host\n  port: 6000\n  user: file-user\n")
+		t.Setenv("LIBRARY_DB_HOST", "env-host")
+		t.Setenv("LIBRARY_DB_PORT", "7000")
+
+		cfg, rest, err := config.Load([]string{"-config", path, "-db-port", "8000", "migrate", "up"})
+		require.NoError(t, err)
+
+		assert.Equal(t, ":8000", cfg.HTTP.Addr)
+		assert.Equal(t, "file-user", cfg.DB.User)
+		assert.Equal(t, "env-host", cfg.DB.Host)
+		assert.Equal(t, 8000, cfg.DB.Port)
+		assert.Equal(t, []string{"migrate", "up"}, rest)
+	})
+
+	t.Run("Load Config - File from environment", func(t *testing.T) {
+		t.Setenv("LIBRARY_CONFIG", writeConfig(t, "db:\n  conn_max_lifetime: 5m\n"))
+
+		cfg, _, err := config.Load(nil)
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, cfg.DB.ConnMaxLifetime)
+	})
+
+	t.Run("Load Config - Unknown file key", func(t *testing.T) {
+		path := writeConfig(t, "db:\n  prot: 5432\n")
+
+		_, _, err := config.Load([]string{"-config", path})
+		assert.ErrorContains(t, err, "prot")
+	})
+
+	t.Run("Load Config - Invalid environment value", func(t *testing.T) {
+		t.Setenv("LIBRARY_DB_PORT", "abc")
+
+		_, _, err := config.Load(nil)
+		assert.ErrorContains(t, err, "LIBRARY_DB_PORT")
+	})
+
+	t.Run("Load Config - All violations", func(t *testing.T) {
+		_, _, err := config.Load([]string{"-db-port", "0", "-db-sslmode", "bogus", "-db-log-level", "debug"})
+		require.Error(t, err)
+
+		assert.ErrorContains(t, err, "db.port (LIBRARY_DB_PORT, -db-port)")
+		assert.ErrorContains(t, err, "db.sslmode")
+		assert.ErrorContains(t, err, "db.log_level")
+	})
+
+	t.Run("Load Config - Include depth", func(t *testing.T) {
+		cfg, _, err := config.Load([]string{"-api-max-include-depth", "3"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, cfg.API.MaxIncludeDepth)
+
+		_, _, err = config.Load([]string{"-api-max-include-depth", "0"})
+		assert.ErrorContains(t, err, "api.max_include_depth")
+	})
+
+	t.Run("Load Config - Signing keys", func(t *testing.T) {
+		key := strings.Repeat("k", config.MinKeySize)
+		cfg, _, err := config.Load([]string{"-auth-signing-keys", "old:" + key + ",new:" + key, "-auth-signing-key-id", "new"})
+		require.NoError(t, err)
+
+		keys, err := cfg.Auth.Keys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+
+		_, _, err = config.Load([]string{"-auth-signing-keys", "old:" + key, "-auth-signing-key-id", "new"})
+		assert.ErrorContains(t, err, "auth.signing_key_id")
+
+		_, _, err = config.Load([]string{"-auth-signing-keys", "old:short", "-auth-signing-key-id", "old"})
+		assert.ErrorContains(t, err, "auth.signing_keys")
+	})
+
+	t.Run("Load Config - SQLite ignores connection settings", func(t *testing.T) {
+		cfg, _, err := config.Load([]string{"-db-driver", "sqlite", "-db-path", "/tmp/library.db", "-db-host", ""})
+		require.NoError(t, err)
+		assert.Equal(t, "sqlite", cfg.DB.Driver)
+		assert.Equal(t, "/tmp/library.db", cfg.DB.Path)
+
+		_, _, err = config.Load([]string{"-db-driver", "sqlite", "-db-path", ""})
+		assert.ErrorContains(t, err, "db.path")
+
+		_, _, err = config.Load([]string{"-db-driver", "mysql"})
+		assert.ErrorContains(t, err, "db.driver")
+	})
+}
+
+func TestConfigSecrets(t *testing.T) {
+	t.Setenv("LIBRARY_DB_PASSWORD", "s3cret")
+	t.Setenv("LIBRARY_AUTH_SIGNING_KEYS", "k1:s3cret-s3cret-s3cret-s3cret-s3cret")
+	t.Setenv("LIBRARY_AUTH_SIGNING_KEY_ID", "k1")
+
+	cfg, _, err := config.Load(nil)
+	require.NoError(t, err)
+
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v", cfg, cfg, cfg), "s3cret")
+
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.Contains(t, cfg.DB.DSN(), "s3cret")
+}
@@ -0,0 +1,57 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type event struct {
+	ID    uint
+	At    time.Time
+	Until *time.Time
+}
+
+func TestSQLiteTimes(t *testing.T) {
+	cfg := config.Default().DB
+	cfg.Driver = "sqlite"
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+
+	db, err := database.Open(cfg, &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&event{}))
+
+	// An hour ago, but as text it sorts after the current time in UTC.
+	plus5 := time.FixedZone("+05:00", 5*60*60)
+	past := time.Now().Add(-time.Hour).In(plus5)
+
+	countBefore := func(t *testing.T, column string) int64 {
+		var n int64
+		require.NoError(t, db.Model(&event{}).Where(column+" < ?", db.NowFunc()).Count(&n).Error)
+		return n
+	}
+
+	t.Run("SQLite Times - Create with offset", func(t *testing.T) {
+		require.NoError(t, db.Create(&event{At: past, Until: &past}).Error)
+
+		assert.Equal(t, int64(1), countBefore(t, "at"))
+		assert.Equal(t, int64(1), countBefore(t, "until"))
+	})
+
+	t.Run("SQLite Times - Update with offset", func(t *testing.T) {
+		item := &event{At: time.Now().Add(time.Hour)}
+		require.NoError(t, db.Create(item).Error)
+
+		require.NoError(t, db.Model(item).Update("until", past).Error)
+		require.NoError(t, db.Model(item).Updates(event{At: past}).Error)
+
+		assert.Equal(t, int64(2), countBefore(t, "at"))
+		assert.Equal(t, int64(2), countBefore(t, "until"))
+	})
+}
@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	send, login := setupRBAC(t)
+	_, admin := login("admin", auth.RoleAdmin)
+
+	withKey := func(method, url, key string, body any) *httptest.ResponseRecorder {
+		return send(method, url, "", body, "ApiKey "+key)
+	}
+
+	create := func(t *testing.T, req models.NewAPIKey) models.CreatedAPIKey {
+		rec := send(http.MethodPost, "/api-keys", admin, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created models.CreatedAPIKey
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		return created
+	}
+
+	get := func(t *testing.T, id string) map[string]any {
+		rec := send(http.MethodGet, "/api-keys/"+id, admin, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("Create API Key - Success", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api-keys", admin, models.NewAPIKey{Name: "kiosk", Scopes: []string{"books:read"}})
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get(echo.HeaderCacheControl))
+
+		var created models.CreatedAPIKey
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		assert.NotEmpty(t, created.Key)
+		assert.Equal(t, []models.APIKeyScope{{Scope: "books:read"}}, created.Scopes)
+
+		resp := get(t, created.ID)
+		assert.Equal(t, "kiosk", resp["name"])
+		assert.NotContains(t, resp, "key")
+		assert.NotContains(t, resp, "Hash")
+		assert.Nil(t, resp["last_used_at"])
+	})
+
+	t.Run("Create API Key - Invalid", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		rec := send(http.MethodPost, "/api-keys", admin, models.NewAPIKey{Scopes: []string{"users:manage"}, ExpiresAt: &past})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p := assertProblem(t, rec, problem.CodeValidationFailed)
+		fields := []string{}
+		for _, e := range p.Errors {
+			fields = append(fields, e.Field)
+		}
+		assert.ElementsMatch(t, []string{"name", "scopes[0]", "expires_at"}, fields)
+	})
+
+	t.Run("API Key - Scopes", func(t *testing.T) {
+		key := create(t, models.NewAPIKey{Name: "discovery", Scopes: []string{"books:read", "books:write"}}).Key
+
+		rec := withKey(http.MethodPost, "/books", key, models.Book{Title: "Solaris", Pages: 204})
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = withKey(http.MethodGet, "/books", key, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = withKey(http.MethodGet, "/authors", key, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assertProblem(t, rec, problem.CodeForbidden)
+
+		rec = withKey(http.MethodPost, "/books", key, models.Book{Title: "Eden", Authors: []*models.Author{{Name: "Stanisław Lem"}}})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = withKey(http.MethodGet, "/api-keys", key, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("API Key - Invalid", func(t *testing.T) {
+		created := create(t, models.NewAPIKey{Name: "kiosk", Scopes: []string{"books:read"}})
+
+		for _, key := range []string{"malformed", created.ID + ".wrong-secret", "lk_unknown.secret"} {
+			rec := withKey(http.MethodGet, "/books", key, nil)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, `ApiKey error="invalid_key"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			assertProblem(t, rec, problem.CodeUnauthorized)
+		}
+	})
+
+	t.Run("API Key - Last use", func(t *testing.T) {
+		created := create(t, models.NewAPIKey{Name: "kiosk", Scopes: []string{"books:read"}})
+
+		rec := withKey(http.MethodGet, "/books", created.Key, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.NotNil(t, get(t, created.ID)["last_used_at"])
+	})
+
+	t.Run("API Key - Expiry", func(t *testing.T) {
+		soon := time.Now().Add(time.Second)
+		created := create(t, models.NewAPIKey{Name: "temporary", Scopes: []string{"books:read"}, ExpiresAt: &soon})
+
+		rec := withKey(http.MethodGet, "/books", created.Key, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		time.Sleep(time.Until(soon) + 10*time.Millisecond)
+		rec = withKey(http.MethodGet, "/books", created.Key, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("API Key - Revoke", func(t *testing.T) {
+		created := create(t, models.NewAPIKey{Name: "kiosk", Scopes: []string{"books:read"}})
+
+		rec := send(http.MethodDelete, "/api-keys/"+created.ID, admin, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		rec = send(http.MethodDelete, "/api-keys/"+created.ID, admin, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = withKey(http.MethodGet, "/books", created.Key, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotNil(t, get(t, created.ID)["revoked_at"])
+
+		rec = send(http.MethodDelete, "/api-keys/lk_unknown", admin, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeAPIKeyNotFound)
+	})
+
+	t.Run("API Key - Rotate", func(t *testing.T) {
+		old := create(t, models.NewAPIKey{Name: "discovery", Scopes: []string{"books:read", "authors:read"}})
+
+		rec := send(http.MethodPost, "/api-keys/"+old.ID+"/rotate", admin, map[string]any{"overlap_seconds": 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var rotated models.CreatedAPIKey
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+		assert.NotEqual(t, old.Key, rotated.Key)
+		assert.Equal(t, "discovery", rotated.Name)
+		assert.ElementsMatch(t, old.Scopes, rotated.Scopes)
+		assert.Equal(t, rotated.ID, get(t, old.ID)["replaced_by"])
+
+		// Both keys work during the overlap window.
+		assert.Equal(t, http.StatusOK, withKey(http.MethodGet, "/authors", old.Key, nil).Code)
+		assert.Equal(t, http.StatusOK, withKey(http.MethodGet, "/authors", rotated.Key, nil).Code)
+
+		rec = send(http.MethodPost, "/api-keys/"+old.ID+"/rotate", admin, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeAPIKeyInactive)
+
+		time.Sleep(1100 * time.Millisecond)
+		assert.Equal(t, http.StatusUnauthorized, withKey(http.MethodGet, "/authors", old.Key, nil).Code)
+		assert.Equal(t, http.StatusOK, withKey(http.MethodGet, "/authors", rotated.Key, nil).Code)
+	})
+
+	t.Run("API Key - Rotate with default overlap", func(t *testing.T) {
+		old := create(t, models.NewAPIKey{Name: "kiosk", Scopes: []string{"books:read"}})
+
+		rec := send(http.MethodPost, "/api-keys/"+old.ID+"/rotate", admin, nil)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var resp models.APIKey
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Nil(t, resp.ExpiresAt)
+
+		expiresAt, err := time.Parse(time.RFC3339, get(t, old.ID)["expires_at"].(string))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), expiresAt, time.Minute)
+	})
+}
@@ -0,0 +1,168 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/migrations"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandler(t *testing.T) {
+	e := echo.New()
+	db := testutils.SetupTestDB(t)
+	defer testutils.FreeTestDB(t, db)
+	require.NoError(t, migrations.Up(db))
+
+	tokens, err := auth.NewTokens(config.Default().Auth)
+	require.NoError(t, err)
+	handlers.SetupRoutes(e, db, tokens, time.Hour, config.Default().Circulation, config.Default().API)
+
+	testutils.CreateUser(t, db, "librarian", "librarian-password", auth.RoleLibrarian)
+
+	send := func(method, url, token string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewReader(raw))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		return send(http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: token})
+	}
+
+	t.Run("Login - Success", func(t *testing.T) {
+		rec := send(http.MethodPost, "/auth/login", "", models.Credentials{Username: "librarian", Password: "librarian-password"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get(echo.HeaderCacheControl))
+
+		var resp models.Tokens
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.NotEmpty(t, resp.RefreshToken)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, 900, resp.ExpiresIn)
+
+		rec = send(http.MethodGet, "/books", resp.AccessToken, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Login - Wrong password", func(t *testing.T) {
+		rec := send(http.MethodPost, "/auth/login", "", models.Credentials{Username: "librarian", Password: "wrong-password"})
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assertProblem(t, rec, problem.CodeInvalidCredentials)
+	})
+
+	t.Run("Login - Unknown user", func(t *testing.T) {
+		rec := send(http.MethodPost, "/auth/login", "", models.Credentials{Username: "nobody", Password: "librarian-password"})
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assertProblem(t, rec, problem.CodeInvalidCredentials)
+	})
+
+	t.Run("Login - Missing password", func(t *testing.T) {
+		rec := send(http.MethodPost, "/auth/login", "", models.Credentials{Username: "librarian"})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		resp := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Equal(t, "password", resp.Errors[0].Field)
+	})
+
+	t.Run("Auth - Missing token", func(t *testing.T) {
+		rec := send(http.MethodGet, "/books", "", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+		assertProblem(t, rec, problem.CodeUnauthorized)
+	})
+
+	t.Run("Auth - Invalid token", func(t *testing.T) {
+		rec := send(http.MethodDelete, "/books/1", "not-a-jwt", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+		assertProblem(t, rec, problem.CodeUnauthorized)
+	})
+
+	t.Run("Auth - Token from another key", func(t *testing.T) {
+		other, err := auth.NewTokens(config.Default().Auth)
+		require.NoError(t, err)
+		token, _, err := other.Issue(1, "session")
+		require.NoError(t, err)
+
+		rec := send(http.MethodGet, "/books", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Refresh - Rotates the refresh token", func(t *testing.T) {
+		login := testutils.Login(t, e, "librarian", "librarian-password")
+
+		rec := refresh(login.RefreshToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Tokens
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotEqual(t, login.RefreshToken, resp.RefreshToken)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/books", resp.AccessToken, nil).Code)
+
+		rec = refresh(resp.RefreshToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Refresh - Reused token ends the session", func(t *testing.T) {
+		login := testutils.Login(t, e, "librarian", "librarian-password")
+
+		rec := refresh(login.RefreshToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var rotated models.Tokens
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+
+		rec = refresh(login.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assertProblem(t, rec, problem.CodeUnauthorized)
+
+		assert.Equal(t, http.StatusUnauthorized, refresh(rotated.RefreshToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/books", rotated.AccessToken, nil).Code)
+	})
+
+	t.Run("Refresh - Malformed token", func(t *testing.T) {
+		rec := refresh("malformed")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assertProblem(t, rec, problem.CodeUnauthorized)
+	})
+
+	t.Run("Logout - Revokes the session", func(t *testing.T) {
+		login := testutils.Login(t, e, "librarian", "librarian-password")
+		require.Equal(t, http.StatusOK, send(http.MethodGet, "/books", login.AccessToken, nil).Code)
+
+		rec := send(http.MethodPost, "/auth/logout", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/books", login.AccessToken, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, refresh(login.RefreshToken).Code)
+
+		rec = send(http.MethodPost, "/auth/logout", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
@@ -2,15 +2,19 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/4otis/library_api_2025/internal/config"
 	"github.com/4otis/library_api_2025/internal/handlers"
 	"github.com/4otis/library_api_2025/internal/migrations"
 	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
 	"github.com/4otis/library_api_2025/internal/repository"
 	testutils "github.com/4otis/library_api_2025/test"
 	"github.com/labstack/echo/v4"
@@ -22,12 +26,14 @@ import (
 func setupAuthorHandler(t *testing.T) (*echo.Echo, *gorm.DB) {
 	e := echo.New()
 	db := testutils.SetupTestDB(t)
-	err := migrations.RunInitMigrations(db)
+	err := migrations.Up(db)
 	if err != nil {
-		t.Fatal("Error. Failed to run InitMigrations.")
+		t.Fatal("Error. Failed to run migrations.")
 	}
 	repo := repository.NewAuthorRepository(db)
-	handler := handlers.NewAuthorHandler(repo)
+	handler := handlers.NewAuthorHandler(repo, config.Default().API.MaxIncludeDepth)
+
+	e.HTTPErrorHandler = handlers.ErrorHandler
 
 	e.POST("/authors", handler.CreateAuthor)
 	e.GET("/authors", handler.ListAuthors)
@@ -74,19 +80,22 @@ func TestCreateAuthorHandler(t *testing.T) {
 
 		e.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, problem.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
 
-		var resp models.Author
+		var resp problem.Problem
 		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
-		assert.Equal(t, author.Name, resp.Name)
-		assert.NotZero(t, resp.ID)
+		assert.Equal(t, problem.CodeValidationFailed, resp.Code)
+		assert.Equal(t, []problem.FieldError{{Field: "name", Message: "is required"}}, resp.Errors)
 	})
 
-	t.Run("Create Author - Invalid ID (already existing)", func(t *testing.T) {
+	t.Run("Create Author - Client ID and version ignored", func(t *testing.T) {
 		author := &models.Author{
-			Name: "author3",
+			Name:    "author3",
+			Version: 7,
 			Model: gorm.Model{
-				ID: 1,
+				ID:        1,
+				CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
 			},
 		}
 		body, _ := json.Marshal(author)
@@ -97,7 +106,14 @@ func TestCreateAuthorHandler(t *testing.T) {
 
 		e.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var resp models.Author
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotEqual(t, uint(1), resp.ID)
+		assert.NotEqual(t, author.CreatedAt, resp.CreatedAt.UTC())
+		assert.Equal(t, uint(1), resp.Version)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
 	})
 
 	t.Run("Create Author - Before book was added", func(t *testing.T) {
@@ -205,7 +221,7 @@ func TestListAuthorHandler(t *testing.T) {
 	}
 
 	for _, author := range authors {
-		require.NoError(t, authorRepo.Create(author))
+		require.NoError(t, authorRepo.Create(context.Background(), author))
 	}
 
 	t.Run("List Author - Success", func(t *testing.T) {
@@ -275,7 +291,7 @@ func TestUpdateAuthorHandler(t *testing.T) {
 		},
 	}
 
-	require.NoError(t, authorRepo.Create(authors[0]))
+	require.NoError(t, authorRepo.Create(context.Background(), authors[0]))
 
 	t.Run("Update Author - Success", func(t *testing.T) {
 		newAuthor := authors[1]
@@ -291,7 +307,7 @@ func TestUpdateAuthorHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		updatedAuthor, err := authorRepo.Read(uint(id))
+		updatedAuthor, err := authorRepo.Read(context.Background(), uint(id), "Books")
 		require.NoError(t, err, "failed to read updated author")
 
 		assert.Equal(t, newAuthor.Name, updatedAuthor.Name)
@@ -314,7 +330,7 @@ func TestUpdateAuthorHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		updatedAuthor, err := authorRepo.Read(uint(id))
+		updatedAuthor, err := authorRepo.Read(context.Background(), uint(id), "Books")
 		require.NoError(t, err, "failed to read updated author")
 
 		assert.Equal(t, newAuthor.Name, updatedAuthor.Name)
@@ -364,7 +380,7 @@ func TestDeleteAuthorHandler(t *testing.T) {
 	}
 
 	for _, author := range authors {
-		require.NoError(t, authorRepo.Create(author))
+		require.NoError(t, authorRepo.Create(context.Background(), author))
 	}
 
 	t.Run("Delete Author - Success", func(t *testing.T) {
@@ -381,7 +397,17 @@ func TestDeleteAuthorHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		_, err := authorRepo.Read(uint(id))
+		_, err := authorRepo.Read(context.Background(), uint(id))
 		require.Error(t, err)
 	})
+
+	t.Run("Delete Author - Not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/authors/999", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeAuthorNotFound)
+	})
 }
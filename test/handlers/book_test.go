@@ -2,15 +2,21 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
 	"github.com/4otis/library_api_2025/internal/handlers"
 	"github.com/4otis/library_api_2025/internal/migrations"
 	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
 	"github.com/4otis/library_api_2025/internal/repository"
 	testutils "github.com/4otis/library_api_2025/test"
 	"github.com/labstack/echo/v4"
@@ -22,16 +28,31 @@ import (
 func setupBookHandler(t *testing.T) (*echo.Echo, *gorm.DB) {
 	e := echo.New()
 	db := testutils.SetupTestDB(t)
-	err := migrations.RunInitMigrations(db)
+	err := migrations.Up(db)
 	if err != nil {
-		t.Fatal("Error. Failed to run InitMigrations.")
+		t.Fatal("Error. Failed to run migrations.")
 	}
 
-	handlers.SetupRoutes(e, db)
+	tokens, err := auth.NewTokens(config.Default().Auth)
+	require.NoError(t, err)
+	handlers.SetupRoutes(e, db, tokens, time.Hour, config.Default().Circulation, config.Default().API)
+	testutils.Authorize(t, e, db)
 
 	return e, db
 }
 
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, code string) problem.Problem {
+	t.Helper()
+
+	assert.Equal(t, problem.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var resp problem.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, code, resp.Code)
+	assert.Equal(t, rec.Code, resp.Status)
+	return resp
+}
+
 func TestCreateBookHandler(t *testing.T) {
 	e, db := setupBookHandler(t)
 	defer testutils.FreeTestDB(t, db)
@@ -70,15 +91,19 @@ func TestCreateBookHandler(t *testing.T) {
 
 		e.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		resp := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Equal(t, []problem.FieldError{{Field: "title", Message: "is required"}}, resp.Errors)
 	})
 
-	t.Run("Create Book - Invalid ID (already existing)", func(t *testing.T) {
+	t.Run("Create Book - Client ID and version ignored", func(t *testing.T) {
 		book := &models.Book{
-			Title: "book3",
-			Pages: 300,
+			Title:   "book3",
+			Pages:   300,
+			Version: 7,
 			Model: gorm.Model{
-				ID: 1,
+				ID:        1,
+				CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
 			},
 		}
 		body, _ := json.Marshal(book)
@@ -89,7 +114,14 @@ func TestCreateBookHandler(t *testing.T) {
 
 		e.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotEqual(t, uint(1), resp.ID)
+		assert.NotEqual(t, book.CreatedAt, resp.CreatedAt.UTC())
+		assert.Equal(t, uint(1), resp.Version)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
 	})
 
 	t.Run("Create Book - With Authors", func(t *testing.T) {
@@ -126,6 +158,85 @@ func TestCreateBookHandler(t *testing.T) {
 	})
 }
 
+func TestValidateBookHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	book := &models.Book{
+		Title:   "b1",
+		Pages:   100,
+		Authors: []*models.Author{{Name: "a1"}},
+	}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	authorID := book.Authors[0].ID
+
+	send := func(t *testing.T, method, url, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("Validate Book - All violations", func(t *testing.T) {
+		body := `{"title": "` + strings.Repeat("t", 65) + `", "pages": -1, "authors": [{"name": ""}, null]}`
+		rec := send(t, http.MethodPost, "/books", echo.MIMEApplicationJSON, body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		resp := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.ElementsMatch(t, []problem.FieldError{
+			{Field: "title", Message: "must be at most 64 characters"},
+			{Field: "pages", Message: "must be at least 0"},
+			{Field: "authors[0].name", Message: "is required"},
+			{Field: "authors[1]", Message: "is required"},
+		}, resp.Errors)
+	})
+
+	t.Run("Validate Book - Existing author reference", func(t *testing.T) {
+		body := `{"title": "b2", "authors": [{"ID": ` + strconv.Itoa(int(authorID)) + `}]}`
+		rec := send(t, http.MethodPost, "/books", echo.MIMEApplicationJSON, body)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		created, err := bookRepo.Read(context.Background(), resp.ID, "Authors")
+		require.NoError(t, err)
+		require.Len(t, created.Authors, 1)
+		assert.Equal(t, "a1", created.Authors[0].Name)
+	})
+
+	t.Run("Validate Book - Missing author reference", func(t *testing.T) {
+		body := `{"title": "b3", "authors": [{"name": "a2"}, {"ID": 999}]}`
+		rec := send(t, http.MethodPut, "/books/"+strconv.Itoa(int(book.ID)), echo.MIMEApplicationJSON, body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		resp := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Equal(t, []problem.FieldError{{Field: "authors[1].ID", Message: "author 999 doesn't exist"}}, resp.Errors)
+
+		var cnt int64
+		db.Model(&models.Author{}).Where("name = ?", "a2").Count(&cnt)
+		assert.Zero(t, cnt)
+	})
+
+	t.Run("Validate Book - Invalid patch result", func(t *testing.T) {
+		rec := send(t, http.MethodPatch, "/books/"+strconv.Itoa(int(book.ID)), handlers.MIMEMergePatch, `{"title": ""}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		resp := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Equal(t, []problem.FieldError{{Field: "title", Message: "is required"}}, resp.Errors)
+
+		stored, err := bookRepo.Read(context.Background(), book.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b1", stored.Title)
+		assert.Equal(t, book.Version, stored.Version)
+	})
+}
+
 func TestGetBookHandler(t *testing.T) {
 	e, db := setupBookHandler(t)
 	defer testutils.FreeTestDB(t, db)
@@ -163,6 +274,86 @@ func TestGetBookHandler(t *testing.T) {
 		e.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeBookNotFound)
+	})
+
+	t.Run("Get Book - Malformed ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/abc", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CodeInvalidID)
+	})
+}
+
+func TestGetBookExpansionHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	book := &models.Book{
+		Title:   "b1",
+		Pages:   100,
+		Authors: []*models.Author{{Name: "a1"}},
+	}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	url := "/books/" + strconv.Itoa(int(book.ID))
+
+	get := func(t *testing.T, query string) map[string]any {
+		req := httptest.NewRequest(http.MethodGet, url+query, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	keys := func(m map[string]any) (keys []string) {
+		for key := range m {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	t.Run("Get Book - Default expansion", func(t *testing.T) {
+		resp := get(t, "")
+
+		authors := resp["authors"].([]any)
+		require.Len(t, authors, 1)
+		assert.NotContains(t, authors[0], "books")
+	})
+
+	t.Run("Get Book - Sparse fields", func(t *testing.T) {
+		resp := get(t, "?fields=title,pages&include=")
+		assert.ElementsMatch(t, []string{"ID", "title", "pages"}, keys(resp))
+	})
+
+	t.Run("Get Book - Nested include", func(t *testing.T) {
+		resp := get(t, "?fields=title&include=authors.books")
+		assert.ElementsMatch(t, []string{"ID", "title", "authors"}, keys(resp))
+
+		authors := resp["authors"].([]any)
+		require.Len(t, authors, 1)
+		books := authors[0].(map[string]any)["books"].([]any)
+		require.Len(t, books, 1)
+		assert.Equal(t, "b1", books[0].(map[string]any)["title"])
+	})
+
+	t.Run("Get Book - Invalid expansion", func(t *testing.T) {
+		for _, query := range []string{"?fields=isbn", "?include=publisher", "?include=authors.books.authors"} {
+			req := httptest.NewRequest(http.MethodGet, url+query, nil)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
 	})
 }
 
@@ -200,7 +391,7 @@ func TestListBooksHandler(t *testing.T) {
 	}
 
 	for _, book := range books {
-		require.NoError(t, bookRepo.Create(book))
+		require.NoError(t, bookRepo.Create(context.Background(), book))
 	}
 
 	t.Run("List Books - Success", func(t *testing.T) {
@@ -244,6 +435,156 @@ func TestListBooksHandler(t *testing.T) {
 	})
 }
 
+func TestListBooksPaginationHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	for i := range 5 {
+		require.NoError(t, bookRepo.Create(context.Background(), &models.Book{
+			Title: "b" + strconv.Itoa(i+1),
+			Pages: 100 * (i + 1),
+		}))
+	}
+
+	list := func(t *testing.T, url string) ([]models.Book, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response []models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response, rec
+	}
+
+	nextLink := func(rec *httptest.ResponseRecorder) string {
+		for _, link := range strings.Split(rec.Header().Get("Link"), ", ") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				return strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+		return ""
+	}
+
+	t.Run("List Books - Cursor pages", func(t *testing.T) {
+		var titles []string
+		url := "/books?limit=2"
+		for url != "" {
+			response, rec := list(t, url)
+			assert.Equal(t, "5", rec.Header().Get("X-Total-Count"))
+			for _, book := range response {
+				titles = append(titles, book.Title)
+			}
+			url = nextLink(rec)
+		}
+
+		assert.Equal(t, []string{"b1", "b2", "b3", "b4", "b5"}, titles)
+	})
+
+	t.Run("List Books - Offset page", func(t *testing.T) {
+		response, rec := list(t, "/books?limit=2&page=3")
+
+		require.Len(t, response, 1)
+		assert.Equal(t, "b5", response[0].Title)
+		assert.Contains(t, rec.Header().Get("Link"), `rel="prev"`)
+		assert.Empty(t, nextLink(rec))
+	})
+
+	t.Run("List Books - Invalid limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books?limit=1000", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestListBooksFilterHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	a1 := &models.Author{
+		Name: "a1",
+	}
+
+	bookRepo := repository.NewBookRepository(db)
+	books := []*models.Book{
+		{
+			Title:   "Go in Action",
+			Pages:   250,
+			Authors: []*models.Author{a1},
+		},
+		{
+			Title: "Learning Go",
+			Pages: 400,
+		},
+		{
+			Title: "Rust Book",
+			Pages: 550,
+		},
+	}
+
+	for _, book := range books {
+		require.NoError(t, bookRepo.Create(context.Background(), book))
+	}
+
+	list := func(t *testing.T, url string) []models.Book {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response []models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	titles := func(books []models.Book) (titles []string) {
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		return titles
+	}
+
+	t.Run("List Books - Title contains", func(t *testing.T) {
+		response := list(t, "/books?title~=go")
+		assert.Equal(t, []string{"Go in Action", "Learning Go"}, titles(response))
+	})
+
+	t.Run("List Books - Pages range", func(t *testing.T) {
+		response := list(t, "/books?pages_min=300&pages_max=500")
+		assert.Equal(t, []string{"Learning Go"}, titles(response))
+	})
+
+	t.Run("List Books - By author", func(t *testing.T) {
+		response := list(t, "/books?author_id="+strconv.Itoa(int(a1.ID)))
+		assert.Equal(t, []string{"Go in Action"}, titles(response))
+	})
+
+	t.Run("List Books - Sort", func(t *testing.T) {
+		response := list(t, "/books?sort=-pages,title")
+		assert.Equal(t, []string{"Rust Book", "Learning Go", "Go in Action"}, titles(response))
+	})
+
+	t.Run("List Books - Unknown field", func(t *testing.T) {
+		for _, url := range []string{"/books?isbn=123", "/books?sort=isbn", "/books?pages_min=many"} {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+			assertProblem(t, rec, problem.CodeInvalidQuery)
+		}
+	})
+}
+
 func TestUpdateBookHandler(t *testing.T) {
 	e, db := setupBookHandler(t)
 	defer testutils.FreeTestDB(t, db)
@@ -269,7 +610,7 @@ func TestUpdateBookHandler(t *testing.T) {
 		},
 	}
 
-	require.NoError(t, bookRepo.Create(books[0]))
+	require.NoError(t, bookRepo.Create(context.Background(), books[0]))
 
 	t.Run("Update Book - Success", func(t *testing.T) {
 		newBook := books[1]
@@ -285,7 +626,7 @@ func TestUpdateBookHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		updatedBook, err := bookRepo.Read(uint(id))
+		updatedBook, err := bookRepo.Read(context.Background(), uint(id))
 		require.NoError(t, err, "failed to read updated book")
 
 		assert.Equal(t, newBook.Title, updatedBook.Title)
@@ -308,13 +649,32 @@ func TestUpdateBookHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		updatedBook, err := bookRepo.Read(uint(id))
+		updatedBook, err := bookRepo.Read(context.Background(), uint(id))
 		require.NoError(t, err, "failed to read updated book")
 
 		assert.Equal(t, newBook.Title, updatedBook.Title)
 		assert.Equal(t, newBook.Pages, updatedBook.Pages)
 	})
 
+	t.Run("Update Book - Full replacement", func(t *testing.T) {
+		id := 1
+
+		req := httptest.NewRequest(http.MethodPut, "/books/"+strconv.Itoa(id), strings.NewReader(`{"title": "b3"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		updatedBook, err := bookRepo.Read(context.Background(), uint(id), "Authors")
+		require.NoError(t, err, "failed to read updated book")
+
+		assert.Equal(t, "b3", updatedBook.Title)
+		assert.Zero(t, updatedBook.Pages)
+		assert.Empty(t, updatedBook.Authors)
+	})
+
 	t.Run("Update Book - Invalid ID (not found)", func(t *testing.T) {
 		id := 999
 		body, _ := json.Marshal(books[0])
@@ -329,6 +689,184 @@ func TestUpdateBookHandler(t *testing.T) {
 	})
 }
 
+func TestPatchBookHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	book := &models.Book{
+		Title:   "b1",
+		Pages:   100,
+		Authors: []*models.Author{{Name: "a1"}, {Name: "a2"}},
+	}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	id := strconv.Itoa(int(book.ID))
+
+	patch := func(t *testing.T, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/books/"+id, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("Patch Book - Merge patch zero value", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEMergePatch, `{"pages": 0}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "b1", resp.Title)
+		assert.Equal(t, 0, resp.Pages)
+		assert.Len(t, resp.Authors, 2)
+	})
+
+	t.Run("Patch Book - JSON patch", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEJSONPatch, `[
+			{"op": "test", "path": "/title", "value": "b1"},
+			{"op": "replace", "path": "/title", "value": "b2"},
+			{"op": "remove", "path": "/authors/0"}
+		]`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		updatedBook, err := bookRepo.Read(context.Background(), book.ID, "Authors")
+		require.NoError(t, err)
+		assert.Equal(t, "b2", updatedBook.Title)
+		require.Len(t, updatedBook.Authors, 1)
+		assert.Equal(t, "a2", updatedBook.Authors[0].Name)
+	})
+
+	t.Run("Patch Book - Clear authors", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEMergePatch, `{"authors": []}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var cnt int64
+		db.Table("books_authors").Where("book_id = ?", book.ID).Count(&cnt)
+		assert.Equal(t, int64(0), cnt)
+	})
+
+	t.Run("Patch Book - Nested author fields", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEMergePatch, `{"authors": [{"ID": 2}]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = patch(t, handlers.MIMEJSONPatch, `[{"op": "replace", "path": "/authors/0/name", "value": "renamed"}]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Contains(t, rec.Body.String(), "authors[0].name")
+
+		rec = patch(t, handlers.MIMEJSONPatch, `[{"op": "add", "path": "/authors/-", "value": {"ID": 1, "name": "renamed"}}]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "authors[1].name")
+
+		for _, id := range []uint{1, 2} {
+			author, err := repository.NewAuthorRepository(db).Read(context.Background(), id)
+			require.NoError(t, err)
+			assert.NotEqual(t, "renamed", author.Name)
+		}
+	})
+
+	t.Run("Patch Book - Failed test operation", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEJSONPatch, `[{"op": "test", "path": "/title", "value": "b1"}]`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodePatchTestFailed)
+	})
+
+	t.Run("Patch Book - Unknown field", func(t *testing.T) {
+		rec := patch(t, handlers.MIMEMergePatch, `{"isbn": "123"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("Patch Book - Unsupported content type", func(t *testing.T) {
+		rec := patch(t, echo.MIMEApplicationJSON, `{"pages": 1}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("Patch Book - Not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/books/999", strings.NewReader(`{"pages": 1}`))
+		req.Header.Set(echo.HeaderContentType, handlers.MIMEMergePatch)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestBookConcurrencyHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	book := &models.Book{
+		Title: "b1",
+		Pages: 100,
+	}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	url := "/books/" + strconv.Itoa(int(book.ID))
+
+	send := func(method, etag, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		if etag != "" {
+			req.Header.Set("If-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("Get Book - ETag", func(t *testing.T) {
+		rec := send(http.MethodGet, "", "", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("Update Book - Current version", func(t *testing.T) {
+		rec := send(http.MethodPut, `"1"`, echo.MIMEApplicationJSON, `{"title": "b2", "pages": 200}`)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("Update Book - Stale version", func(t *testing.T) {
+		rec := send(http.MethodPut, `"1"`, echo.MIMEApplicationJSON, `{"title": "b3", "pages": 300}`)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = send(http.MethodPatch, `"1"`, handlers.MIMEMergePatch, `{"pages": 300}`)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		updatedBook, err := bookRepo.Read(context.Background(), book.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b2", updatedBook.Title)
+		assert.Equal(t, uint(2), updatedBook.Version)
+	})
+
+	t.Run("Patch Book - Current version", func(t *testing.T) {
+		rec := send(http.MethodPatch, `"2"`, handlers.MIMEMergePatch, `{"pages": 300}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("Delete Book - Stale version", func(t *testing.T) {
+		rec := send(http.MethodDelete, `"2"`, "", "")
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = send(http.MethodDelete, `"3"`, "", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
+
 func TestDeleteBookHandler(t *testing.T) {
 	e, db := setupBookHandler(t)
 	defer testutils.FreeTestDB(t, db)
@@ -355,7 +893,7 @@ func TestDeleteBookHandler(t *testing.T) {
 	}
 
 	for _, book := range books {
-		require.NoError(t, bookRepo.Create(book))
+		require.NoError(t, bookRepo.Create(context.Background(), book))
 	}
 
 	t.Run("Delete Book - Success", func(t *testing.T) {
@@ -372,7 +910,17 @@ func TestDeleteBookHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 
-		_, err := bookRepo.Read(uint(id))
+		_, err := bookRepo.Read(context.Background(), uint(id))
 		require.Error(t, err)
 	})
+
+	t.Run("Delete Book - Not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/books/999", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeBookNotFound)
+	})
 }
@@ -0,0 +1,256 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCopy(barcode string) *models.Copy {
+	return &models.Copy{
+		Barcode:   barcode,
+		Location:  "A-12",
+		Condition: models.ConditionGood,
+		Status:    models.CopyAvailable,
+	}
+}
+
+func TestCopyHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	copyRepo := repository.NewCopyRepository(db, config.Default().Circulation)
+
+	book := &models.Book{Title: "Dune", Pages: 412}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	other := &models.Book{Title: "Solaris", Pages: 204}
+	require.NoError(t, bookRepo.Create(context.Background(), other))
+	copiesURL := fmt.Sprintf("/books/%d/copies", book.ID)
+
+	sendJSON := func(method, url string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, strings.NewReader(string(raw)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("Create Copy - Success", func(t *testing.T) {
+		item := newCopy("0000000001")
+		item.Condition = ""
+		item.Status = ""
+		item.PriceCents = 1299
+
+		rec := sendJSON(http.MethodPost, copiesURL, item)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+		var resp models.Copy
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotZero(t, resp.ID)
+		assert.Equal(t, book.ID, resp.BookID)
+		assert.Equal(t, int64(1299), resp.PriceCents)
+		assert.Equal(t, models.ConditionGood, resp.Condition)
+		assert.Equal(t, models.CopyAvailable, resp.Status)
+	})
+
+	t.Run("Create Copy - Duplicate barcode", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, fmt.Sprintf("/books/%d/copies", other.ID), newCopy("0000000001"))
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeConflict)
+	})
+
+	t.Run("Create Copy - Book not found", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, "/books/999/copies", newCopy("0000000002"))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeBookNotFound)
+	})
+
+	t.Run("Create Copy - Invalid", func(t *testing.T) {
+		item := newCopy("")
+		item.Status = "borrowed"
+		item.PriceCents = -1
+
+		rec := sendJSON(http.MethodPost, copiesURL, item)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p := assertProblem(t, rec, problem.CodeValidationFailed)
+		assert.Len(t, p.Errors, 3)
+	})
+
+	t.Run("Get Copy - Of another book", func(t *testing.T) {
+		item := newCopy("0000000003")
+		require.NoError(t, copyRepo.Create(context.Background(), other.ID, item))
+
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("%s/%d", copiesURL, item.ID), nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeCopyNotFound)
+
+		rec = sendJSON(http.MethodGet, fmt.Sprintf("/books/%d/copies/%d", other.ID, item.ID), nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Update Copy - Mark lost", func(t *testing.T) {
+		item := newCopy("0000000004")
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		url := fmt.Sprintf("%s/%d", copiesURL, item.ID)
+
+		replacement := newCopy("0000000004")
+		replacement.Status = models.CopyLost
+		rec := sendJSON(http.MethodPut, url, replacement)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		updated, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyLost, updated.Status)
+		assert.Equal(t, book.ID, updated.BookID)
+	})
+
+	t.Run("List Copies - Filter by status", func(t *testing.T) {
+		rec := sendJSON(http.MethodGet, copiesURL+"?status=available", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var copies []models.Copy
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &copies))
+		require.Len(t, copies, 1)
+		assert.Equal(t, "0000000001", copies[0].Barcode)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Get Book - Availability", func(t *testing.T) {
+		item := newCopy("0000000005")
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		require.NoError(t, db.Model(item).Update("status", models.CopyOnLoan).Error)
+
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("/books/%d", book.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.NotNil(t, resp.Availability)
+		assert.Equal(t, models.Availability{Total: 3, Available: 1, OnLoan: 1, Lost: 1}, *resp.Availability)
+
+		rec = sendJSON(http.MethodGet, fmt.Sprintf("/books/%d?fields=title", book.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "availability")
+	})
+
+	t.Run("Create Copy - Circulation status", func(t *testing.T) {
+		for _, status := range []string{models.CopyOnLoan, models.CopyOnHold} {
+			item := newCopy("0000000007")
+			item.Status = status
+
+			rec := sendJSON(http.MethodPost, copiesURL, item)
+
+			assert.Equal(t, http.StatusConflict, rec.Code, status)
+			assertProblem(t, rec, problem.CodeCirculationStatus)
+		}
+	})
+
+	t.Run("Update Copy - Omitted status is kept", func(t *testing.T) {
+		item := newCopy("0000000008")
+		item.Status = models.CopyInTransit
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+
+		replacement := newCopy("0000000008")
+		replacement.Status = ""
+		replacement.Location = "B-03"
+		rec := sendJSON(http.MethodPut, fmt.Sprintf("%s/%d", copiesURL, item.ID), replacement)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		updated, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyInTransit, updated.Status)
+		assert.Equal(t, "B-03", updated.Location)
+	})
+
+	t.Run("Update Copy - Into circulation status", func(t *testing.T) {
+		item := newCopy("0000000009")
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		url := fmt.Sprintf("%s/%d", copiesURL, item.ID)
+
+		for _, status := range []string{models.CopyOnLoan, models.CopyOnHold} {
+			replacement := newCopy("0000000009")
+			replacement.Status = status
+
+			rec := sendJSON(http.MethodPut, url, replacement)
+
+			assert.Equal(t, http.StatusConflict, rec.Code, status)
+			assertProblem(t, rec, problem.CodeCirculationStatus)
+		}
+
+		stored, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyAvailable, stored.Status)
+	})
+
+	t.Run("Update Copy - Out of circulation status", func(t *testing.T) {
+		item := newCopy("0000000010")
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		require.NoError(t, db.Model(item).Update("status", models.CopyOnLoan).Error)
+		url := fmt.Sprintf("%s/%d", copiesURL, item.ID)
+
+		replacement := newCopy("0000000010")
+		rec := sendJSON(http.MethodPut, url, replacement)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeCirculationStatus)
+
+		// Other fields of a copy on loan can still be edited.
+		replacement.Status = models.CopyOnLoan
+		replacement.Location = "C-01"
+		rec = sendJSON(http.MethodPut, url, replacement)
+		assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Delete Copy - Circulating", func(t *testing.T) {
+		for i, status := range []string{models.CopyOnLoan, models.CopyOnHold} {
+			item := newCopy(fmt.Sprintf("000000002%d", i))
+			require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+			require.NoError(t, db.Model(item).Update("status", status).Error)
+			url := fmt.Sprintf("%s/%d", copiesURL, item.ID)
+
+			rec := sendJSON(http.MethodDelete, url, nil)
+			assert.Equal(t, http.StatusConflict, rec.Code, status)
+			assertProblem(t, rec, problem.CodeCirculationStatus)
+
+			rec = sendJSON(http.MethodGet, url, nil)
+			assert.Equal(t, http.StatusOK, rec.Code, status)
+		}
+	})
+
+	t.Run("Delete Copy - Success", func(t *testing.T) {
+		item := newCopy("0000000006")
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		url := fmt.Sprintf("%s/%d", copiesURL, item.ID)
+
+		rec := sendJSON(http.MethodDelete, url, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = sendJSON(http.MethodGet, url, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		// The barcode is free again once its copy is deleted.
+		rec = sendJSON(http.MethodPost, copiesURL, newCopy("0000000006"))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
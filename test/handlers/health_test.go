@@ -0,0 +1,84 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/health"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	var draining, dbDown atomic.Bool
+
+	ready := health.NewChecker(time.Second)
+	ready.Add("shutdown", health.NotDraining(draining.Load))
+	ready.Add("database", func(context.Context) error {
+		if dbDown.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	e := echo.New()
+	handlers.SetupHealthRoutes(e, handlers.NewHealthHandler(ready))
+
+	probe := func(t *testing.T, url string) (*httptest.ResponseRecorder, health.Report) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec, report
+	}
+
+	t.Run("Health - Live", func(t *testing.T) {
+		rec, report := probe(t, "/healthz")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+	})
+
+	t.Run("Health - Ready", func(t *testing.T) {
+		rec, report := probe(t, "/readyz")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Equal(t, health.StatusOK, report.Checks["shutdown"].Status)
+		assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	})
+
+	t.Run("Health - Dependency down", func(t *testing.T) {
+		dbDown.Store(true)
+		defer dbDown.Store(false)
+
+		rec, report := probe(t, "/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, health.StatusFail, report.Status)
+		assert.Equal(t, health.StatusOK, report.Checks["shutdown"].Status)
+		assert.Equal(t, "connection refused", report.Checks["database"].Error)
+	})
+
+	t.Run("Health - Draining", func(t *testing.T) {
+		draining.Store(true)
+
+		rec, report := probe(t, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, health.StatusFail, report.Checks["shutdown"].Status)
+
+		rec, _ = probe(t, "/healthz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,368 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	copyRepo := repository.NewCopyRepository(db, config.Default().Circulation)
+	memberRepo := repository.NewMemberRepository(db)
+	holdRepo := repository.NewHoldRepository(db, config.Default().Circulation)
+
+	book := &models.Book{Title: "Dune", Pages: 412}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+	item := newCopy("0000000001")
+	require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+	holdsURL := fmt.Sprintf("/books/%d/holds", book.ID)
+
+	cards := 0
+	addMember := func(name string) *models.Member {
+		cards++
+		member := newMember(fmt.Sprintf("C-%04d", cards), name)
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+		return member
+	}
+
+	sendJSON := func(method, url string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, strings.NewReader(string(raw)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	decode := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v), rec.Body.String())
+	}
+
+	checkout := func(member *models.Member) *httptest.ResponseRecorder {
+		return sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: item.ID, MemberID: member.ID})
+	}
+
+	place := func(member *models.Member) models.Hold {
+		t.Helper()
+		rec := sendJSON(http.MethodPost, holdsURL, models.NewHold{MemberID: member.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var hold models.Hold
+		decode(rec, &hold)
+		return hold
+	}
+
+	getHold := func(id uint) models.Hold {
+		t.Helper()
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("/holds/%d", id), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var hold models.Hold
+		decode(rec, &hold)
+		return hold
+	}
+
+	returnCopy := func() models.Loan {
+		t.Helper()
+		var open []models.Loan
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("/books/%d/copies/%d/loans?returned=false", book.ID, item.ID), nil)
+		decode(rec, &open)
+		require.Len(t, open, 1)
+
+		rec = sendJSON(http.MethodPost, fmt.Sprintf("/loans/%d/return", open[0].ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var loan models.Loan
+		decode(rec, &loan)
+		return loan
+	}
+
+	ada, bob, cyd := addMember("Ada"), addMember("Bob"), addMember("Cyd")
+	var bobHold, cydHold models.Hold
+
+	t.Run("Place Hold - Copy available", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, holdsURL, models.NewHold{MemberID: bob.ID})
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeCopiesAvailable)
+	})
+
+	t.Run("Place Hold - No copies", func(t *testing.T) {
+		empty := &models.Book{Title: "Solaris", Pages: 204}
+		require.NoError(t, bookRepo.Create(context.Background(), empty))
+
+		rec := sendJSON(http.MethodPost, fmt.Sprintf("/books/%d/holds", empty.ID), models.NewHold{MemberID: bob.ID})
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeNoCopies)
+	})
+
+	t.Run("Place Hold - Queue", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, checkout(ada).Code)
+
+		bobHold = place(bob)
+		assert.Equal(t, models.HoldWaiting, bobHold.Status)
+		assert.Equal(t, 1, bobHold.Position)
+
+		cydHold = place(cyd)
+		assert.Equal(t, 2, cydHold.Position)
+
+		rec := sendJSON(http.MethodPost, holdsURL, models.NewHold{MemberID: bob.ID})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeHoldExists)
+
+		rec = sendJSON(http.MethodGet, holdsURL, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var queue []models.Hold
+		decode(rec, &queue)
+		require.Len(t, queue, 2)
+		assert.Equal(t, bob.ID, queue[0].MemberID)
+		assert.Equal(t, []int{1, 2}, []int{queue[0].Position, queue[1].Position})
+	})
+
+	t.Run("Return - Assigns the first hold", func(t *testing.T) {
+		loan := returnCopy()
+
+		require.NotNil(t, loan.AssignedHold)
+		assert.Equal(t, bobHold.ID, loan.AssignedHold.ID)
+
+		hold := getHold(bobHold.ID)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, item.ID, *hold.CopyID)
+		assert.Equal(t, 72*time.Hour, hold.PickupBy.Sub(*hold.ReadyAt))
+		assert.Zero(t, hold.Position)
+		assert.Equal(t, 1, getHold(cydHold.ID).Position)
+
+		stored, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyOnHold, stored.Status)
+	})
+
+	t.Run("Checkout - Held for another member", func(t *testing.T) {
+		rec := checkout(cyd)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeCopyOnHold)
+	})
+
+	t.Run("Expire Pickups - Next in line", func(t *testing.T) {
+		require.NoError(t, db.Model(&models.Hold{}).Where("id = ?", bobHold.ID).
+			Update("pickup_by", time.Now().Add(-time.Minute).UTC()).Error)
+
+		expired, err := holdRepo.ExpirePickups(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		assert.Equal(t, models.HoldExpired, getHold(bobHold.ID).Status)
+		hold := getHold(cydHold.ID)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, item.ID, *hold.CopyID)
+
+		expired, err = holdRepo.ExpirePickups(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, expired)
+	})
+
+	t.Run("Checkout - Held for the member", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, checkout(cyd).Code)
+
+		assert.Equal(t, models.HoldFulfilled, getHold(cydHold.ID).Status)
+	})
+
+	t.Run("Cancel Hold - Waiting", func(t *testing.T) {
+		hold := place(addMember("Dee"))
+		url := fmt.Sprintf("/holds/%d/cancel", hold.ID)
+
+		rec := sendJSON(http.MethodPost, url, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		decode(rec, &hold)
+		assert.Equal(t, models.HoldCancelled, hold.Status)
+
+		rec = sendJSON(http.MethodPost, url, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeHoldClosed)
+	})
+
+	t.Run("Return - Skips members who can't borrow", func(t *testing.T) {
+		eve, fay := addMember("Eve"), addMember("Fay")
+		eveHold, fayHold := place(eve), place(fay)
+
+		eve.Status = models.MemberSuspended
+		require.NoError(t, memberRepo.Update(context.Background(), eve.ID, 0, eve))
+
+		loan := returnCopy()
+		require.NotNil(t, loan.AssignedHold)
+		assert.Equal(t, fayHold.ID, loan.AssignedHold.ID)
+		assert.Equal(t, 1, getHold(eveHold.ID).Position)
+
+		// Once Eve can borrow again, Fay's cancelled pickup goes to her.
+		eve.Status = models.MemberActive
+		require.NoError(t, memberRepo.Update(context.Background(), eve.ID, 0, eve))
+
+		rec := sendJSON(http.MethodPost, fmt.Sprintf("/holds/%d/cancel", fayHold.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		hold := getHold(eveHold.ID)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, item.ID, *hold.CopyID)
+	})
+
+	t.Run("List Member Holds", func(t *testing.T) {
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("/members/%d/holds?status=expired", bob.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Checkout - Other copy than the one held", func(t *testing.T) {
+		other := &models.Book{Title: "Hyperion", Pages: 482}
+		require.NoError(t, bookRepo.Create(context.Background(), other))
+		held := newCopy("0000000101")
+		require.NoError(t, copyRepo.Create(context.Background(), other.ID, held))
+		otherHolds := fmt.Sprintf("/books/%d/holds", other.ID)
+		dan, eve, fay := addMember("Dan"), addMember("Eve"), addMember("Fay")
+
+		rec := sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: held.ID, MemberID: dan.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var loan models.Loan
+		decode(rec, &loan)
+
+		rec = sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: eve.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var eveHold models.Hold
+		decode(rec, &eveHold)
+		rec = sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: fay.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var fayHold models.Hold
+		decode(rec, &fayHold)
+
+		rec = sendJSON(http.MethodPost, fmt.Sprintf("/loans/%d/return", loan.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, models.HoldReady, getHold(eveHold.ID).Status)
+
+		// Eve takes a copy that arrived later instead of the held one.
+		// It's shelved while Fay can't borrow.
+		fay.Status = models.MemberSuspended
+		require.NoError(t, memberRepo.Update(context.Background(), fay.ID, 0, fay))
+		shelved := newCopy("0000000102")
+		require.NoError(t, copyRepo.Create(context.Background(), other.ID, shelved))
+		require.Equal(t, models.CopyAvailable, shelved.Status)
+		fay.Status = models.MemberActive
+		require.NoError(t, memberRepo.Update(context.Background(), fay.ID, 0, fay))
+		rec = sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: shelved.ID, MemberID: eve.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		assert.Equal(t, models.HoldFulfilled, getHold(eveHold.ID).Status)
+		next := getHold(fayHold.ID)
+		assert.Equal(t, models.HoldReady, next.Status)
+		require.NotNil(t, next.CopyID)
+		assert.Equal(t, held.ID, *next.CopyID)
+
+		stored, err := copyRepo.Read(context.Background(), other.ID, held.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyOnHold, stored.Status)
+	})
+
+	t.Run("Return - Skips memberships expired in another time zone", func(t *testing.T) {
+		other := &models.Book{Title: "Neuromancer", Pages: 271}
+		require.NoError(t, bookRepo.Create(context.Background(), other))
+		single := newCopy("0000000201")
+		require.NoError(t, copyRepo.Create(context.Background(), other.ID, single))
+		otherHolds := fmt.Sprintf("/books/%d/holds", other.ID)
+		gus, hal, ivy := addMember("Gus"), addMember("Hal"), addMember("Ivy")
+
+		rec := sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: single.ID, MemberID: gus.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var loan models.Loan
+		decode(rec, &loan)
+
+		rec = sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: hal.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		rec = sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: ivy.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var ivyHold models.Hold
+		decode(rec, &ivyHold)
+
+		// An hour ago in +05:00, which sorts after now as text in UTC.
+		hal.ExpiresAt = time.Now().Add(-time.Hour).In(time.FixedZone("", 5*60*60))
+		rec = sendJSON(http.MethodPut, fmt.Sprintf("/members/%d", hal.ID), hal)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		rec = sendJSON(http.MethodPost, fmt.Sprintf("/loans/%d/return", loan.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		decode(rec, &loan)
+		require.NotNil(t, loan.AssignedHold)
+		assert.Equal(t, ivyHold.ID, loan.AssignedHold.ID)
+	})
+
+	t.Run("Copy Available - Goes to the queue", func(t *testing.T) {
+		other := &models.Book{Title: "Foundation", Pages: 255}
+		require.NoError(t, bookRepo.Create(context.Background(), other))
+		first := newCopy("0000000301")
+		first.Status = models.CopyInTransit
+		require.NoError(t, copyRepo.Create(context.Background(), other.ID, first))
+		otherHolds := fmt.Sprintf("/books/%d/holds", other.ID)
+		jay, kim := addMember("Jay"), addMember("Kim")
+
+		rec := sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: jay.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var jayHold models.Hold
+		decode(rec, &jayHold)
+		rec = sendJSON(http.MethodPost, otherHolds, models.NewHold{MemberID: kim.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var kimHold models.Hold
+		decode(rec, &kimHold)
+
+		// The copy in transit arrives.
+		arrived := newCopy("0000000301")
+		rec = sendJSON(http.MethodPut, fmt.Sprintf("/books/%d/copies/%d", other.ID, first.ID), arrived)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		stored, err := copyRepo.Read(context.Background(), other.ID, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyOnHold, stored.Status)
+		hold := getHold(jayHold.ID)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, first.ID, *hold.CopyID)
+
+		// A new copy goes to the next in line.
+		rec = sendJSON(http.MethodPost, fmt.Sprintf("/books/%d/copies", other.ID), newCopy("0000000302"))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created models.Copy
+		decode(rec, &created)
+		assert.Equal(t, models.CopyOnHold, created.Status)
+		hold = getHold(kimHold.ID)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, created.ID, *hold.CopyID)
+
+		// With the queue served, the next copy is shelved.
+		rec = sendJSON(http.MethodPost, fmt.Sprintf("/books/%d/copies", other.ID), newCopy("0000000303"))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		decode(rec, &created)
+		assert.Equal(t, models.CopyAvailable, created.Status)
+	})
+
+	t.Run("Get Hold - Not found", func(t *testing.T) {
+		rec := sendJSON(http.MethodGet, "/holds/999", nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeHoldNotFound)
+	})
+}
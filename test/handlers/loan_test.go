@@ -0,0 +1,333 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoanHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	copyRepo := repository.NewCopyRepository(db, config.Default().Circulation)
+	memberRepo := repository.NewMemberRepository(db)
+
+	book := &models.Book{Title: "Dune", Pages: 412}
+	require.NoError(t, bookRepo.Create(context.Background(), book))
+
+	barcodes := 0
+	addCopy := func() *models.Copy {
+		barcodes++
+		item := newCopy(fmt.Sprintf("%010d", barcodes))
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		return item
+	}
+	cards := 0
+	addMember := func() *models.Member {
+		cards++
+		member := newMember(fmt.Sprintf("C-%04d", cards), "Reader")
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+		return member
+	}
+
+	sendJSON := func(method, url string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, strings.NewReader(string(raw)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	checkout := func(item *models.Copy, member *models.Member) *httptest.ResponseRecorder {
+		return sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: item.ID, MemberID: member.ID})
+	}
+
+	item, member := addCopy(), addMember()
+	var loan models.Loan
+
+	t.Run("Checkout - Success", func(t *testing.T) {
+		rec := checkout(item, member)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loan))
+		assert.Equal(t, item.ID, loan.CopyID)
+		assert.Equal(t, member.ID, loan.MemberID)
+		assert.Equal(t, 14*24*time.Hour, loan.DueAt.Sub(loan.LoanedAt))
+		assert.Nil(t, loan.ReturnedAt)
+
+		stored, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyOnLoan, stored.Status)
+		assert.Equal(t, uint(2), stored.Version)
+	})
+
+	t.Run("Checkout - Copy on loan", func(t *testing.T) {
+		rec := checkout(item, addMember())
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeCopyUnavailable)
+	})
+
+	t.Run("Checkout - Two desks at once", func(t *testing.T) {
+		contested := addCopy()
+		members := []*models.Member{addMember(), addMember(), addMember(), addMember()}
+
+		codes := make([]int, len(members))
+		var wg sync.WaitGroup
+		for i, m := range members {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i] = checkout(contested, m).Code
+			}()
+		}
+		wg.Wait()
+
+		assert.ElementsMatch(t, []int{http.StatusCreated, http.StatusConflict, http.StatusConflict, http.StatusConflict}, codes)
+	})
+
+	t.Run("Checkout - Member can't borrow", func(t *testing.T) {
+		suspended := addMember()
+		suspended.Status = models.MemberSuspended
+		require.NoError(t, memberRepo.Update(context.Background(), suspended.ID, 0, suspended))
+		expired := addMember()
+		expired.ExpiresAt = time.Now().AddDate(0, 0, -1)
+		require.NoError(t, memberRepo.Update(context.Background(), expired.ID, 0, expired))
+
+		available := addCopy()
+		for _, m := range []*models.Member{suspended, expired} {
+			rec := checkout(available, m)
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assertProblem(t, rec, problem.CodeMemberInactive)
+		}
+	})
+
+	t.Run("Checkout - Loan limit", func(t *testing.T) {
+		busy := addMember()
+		for range 5 {
+			rec := checkout(addCopy(), busy)
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		rec := checkout(addCopy(), busy)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanLimitReached)
+	})
+
+	t.Run("Checkout - Missing copy and member", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: 999, MemberID: member.ID})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p := assertProblem(t, rec, problem.CodeValidationFailed)
+		require.Len(t, p.Errors, 1)
+		assert.Equal(t, "copy_id", p.Errors[0].Field)
+
+		rec = sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: addCopy().ID, MemberID: 999})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p = assertProblem(t, rec, problem.CodeValidationFailed)
+		require.Len(t, p.Errors, 1)
+		assert.Equal(t, "member_id", p.Errors[0].Field)
+
+		rec = sendJSON(http.MethodPost, "/loans", models.Checkout{})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("Return - Success", func(t *testing.T) {
+		url := fmt.Sprintf("/loans/%d/return", loan.ID)
+
+		rec := sendJSON(http.MethodPost, url, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp models.Loan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotNil(t, resp.ReturnedAt)
+
+		stored, err := copyRepo.Read(context.Background(), book.ID, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CopyAvailable, stored.Status)
+
+		rec = sendJSON(http.MethodPost, url, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanReturned)
+	})
+
+	t.Run("Return - Not found", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, "/loans/999/return", nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanNotFound)
+	})
+
+	t.Run("History - Per member and per copy", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, checkout(item, member).Code)
+
+		rec := sendJSON(http.MethodGet, fmt.Sprintf("/members/%d/loans", member.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+
+		rec = sendJSON(http.MethodGet, fmt.Sprintf("/members/%d/loans?returned=false", member.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var open []models.Loan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &open))
+		require.Len(t, open, 1)
+		assert.Nil(t, open[0].ReturnedAt)
+
+		rec = sendJSON(http.MethodGet, fmt.Sprintf("/books/%d/copies/%d/loans?sort=-loaned_at", book.ID, item.ID), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var history []models.Loan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+		require.Len(t, history, 2)
+		assert.Nil(t, history[0].ReturnedAt)
+		assert.NotNil(t, history[1].ReturnedAt)
+
+		rec = sendJSON(http.MethodGet, "/members/999/loans", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeMemberNotFound)
+
+		rec = sendJSON(http.MethodGet, fmt.Sprintf("/members/%d/loans?returned=maybe", member.ID), nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestRenewLoanHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	copyRepo := repository.NewCopyRepository(db, config.Default().Circulation)
+	memberRepo := repository.NewMemberRepository(db)
+
+	sendJSON := func(method, url string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, strings.NewReader(string(raw)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// lend checks out the only copy of a new book to a new member.
+	n := 0
+	lend := func() (models.Loan, *models.Member) {
+		t.Helper()
+		n++
+		book := &models.Book{Title: fmt.Sprintf("Book %d", n), Pages: 100}
+		require.NoError(t, bookRepo.Create(context.Background(), book))
+		item := newCopy(fmt.Sprintf("%010d", n))
+		require.NoError(t, copyRepo.Create(context.Background(), book.ID, item))
+		member := newMember(fmt.Sprintf("C-%04d", n), "Reader")
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+
+		rec := sendJSON(http.MethodPost, "/loans", models.Checkout{CopyID: item.ID, MemberID: member.ID})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var loan models.Loan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loan))
+		return loan, member
+	}
+
+	renew := func(loan models.Loan) *httptest.ResponseRecorder {
+		return sendJSON(http.MethodPost, fmt.Sprintf("/loans/%d/renew", loan.ID), nil)
+	}
+
+	setDue := func(loan models.Loan, due time.Time) {
+		t.Helper()
+		require.NoError(t, db.Model(&models.Loan{}).Where("id = ?", loan.ID).Update("due_at", due.UTC()).Error)
+	}
+
+	t.Run("Renew - Success up to the limit", func(t *testing.T) {
+		loan, _ := lend()
+		setDue(loan, time.Now().Add(24*time.Hour))
+
+		rec := renew(loan)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var renewed models.Loan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &renewed))
+		assert.Equal(t, 1, renewed.Renewals)
+		assert.WithinDuration(t, time.Now().Add(14*24*time.Hour), renewed.DueAt, time.Minute)
+
+		require.Equal(t, http.StatusOK, renew(loan).Code)
+
+		rec = renew(loan)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeRenewalLimitReached)
+	})
+
+	t.Run("Renew - Another member holds the book", func(t *testing.T) {
+		loan, member := lend()
+		other := newMember("H-0001", "Waiting Reader")
+		require.NoError(t, memberRepo.Create(context.Background(), other))
+
+		var item models.Copy
+		require.NoError(t, db.First(&item, loan.CopyID).Error)
+		holdsURL := fmt.Sprintf("/books/%d/holds", item.BookID)
+
+		// The borrower's own hold doesn't stand in the way.
+		require.Equal(t, http.StatusCreated, sendJSON(http.MethodPost, holdsURL, models.NewHold{MemberID: member.ID}).Code)
+		require.Equal(t, http.StatusOK, renew(loan).Code)
+
+		require.Equal(t, http.StatusCreated, sendJSON(http.MethodPost, holdsURL, models.NewHold{MemberID: other.ID}).Code)
+		rec := renew(loan)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeHoldPending)
+	})
+
+	t.Run("Renew - Member blocked", func(t *testing.T) {
+		loan, member := lend()
+		member.Status = models.MemberSuspended
+		require.NoError(t, memberRepo.Update(context.Background(), member.ID, 0, member))
+
+		rec := renew(loan)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeMemberInactive)
+	})
+
+	t.Run("Renew - Overdue", func(t *testing.T) {
+		loan, _ := lend()
+
+		setDue(loan, time.Now().Add(-4*24*time.Hour))
+		rec := renew(loan)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanOverdue)
+
+		// Within the grace period it can still be renewed.
+		setDue(loan, time.Now().Add(-24*time.Hour))
+		assert.Equal(t, http.StatusOK, renew(loan).Code)
+	})
+
+	t.Run("Renew - Returned", func(t *testing.T) {
+		loan, _ := lend()
+		require.Equal(t, http.StatusOK, sendJSON(http.MethodPost, fmt.Sprintf("/loans/%d/return", loan.ID), nil).Code)
+
+		rec := renew(loan)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanReturned)
+	})
+
+	t.Run("Renew - Not found", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, "/loans/999/renew", nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeLoanNotFound)
+	})
+}
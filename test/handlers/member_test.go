@@ -0,0 +1,217 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMember(cardNumber, name string) *models.Member {
+	return &models.Member{
+		CardNumber: cardNumber,
+		Name:       name,
+		Type:       models.MemberAdult,
+		Status:     models.MemberActive,
+		ExpiresAt:  time.Now().AddDate(1, 0, 0).UTC().Truncate(time.Second),
+	}
+}
+
+func TestMemberHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	memberRepo := repository.NewMemberRepository(db)
+
+	send := func(method, url, contentType string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	sendJSON := func(method, url string, body any) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		return send(method, url, echo.MIMEApplicationJSON, string(raw))
+	}
+
+	t.Run("Create Member - Success", func(t *testing.T) {
+		member := newMember("C-0001", "Ada Lovelace")
+		member.Type = ""
+		member.Status = ""
+		member.Email = "ada@example.com"
+
+		rec := sendJSON(http.MethodPost, "/members", member)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+		var resp models.Member
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotZero(t, resp.ID)
+		assert.Equal(t, "C-0001", resp.CardNumber)
+		assert.Equal(t, models.MemberAdult, resp.Type)
+		assert.Equal(t, models.MemberActive, resp.Status)
+	})
+
+	t.Run("Create Member - Duplicate card number", func(t *testing.T) {
+		rec := sendJSON(http.MethodPost, "/members", newMember("C-0001", "Someone Else"))
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeConflict)
+	})
+
+	t.Run("Create Member - Invalid", func(t *testing.T) {
+		member := newMember("", "")
+		member.Email = "not-an-email"
+		member.Status = "banned"
+		member.ExpiresAt = time.Time{}
+
+		rec := sendJSON(http.MethodPost, "/members", member)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p := assertProblem(t, rec, problem.CodeValidationFailed)
+		messages := map[string]string{}
+		for _, e := range p.Errors {
+			messages[e.Field] = e.Message
+		}
+		assert.Equal(t, map[string]string{
+			"card_number": "is required",
+			"name":        "is required",
+			"email":       "must be an email address",
+			"status":      "must be one of active, suspended, expired",
+			"expires_at":  "is required",
+		}, messages)
+	})
+
+	t.Run("Get Member - Not found", func(t *testing.T) {
+		rec := send(http.MethodGet, "/members/999", "", "")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeMemberNotFound)
+	})
+
+	t.Run("List Members - Search by card number or name", func(t *testing.T) {
+		require.NoError(t, memberRepo.Create(context.Background(), newMember("C-0002", "Charles Babbage")))
+		require.NoError(t, memberRepo.Create(context.Background(), newMember("C-0003", "Grace Hopper")))
+
+		search := func(q string) []string {
+			rec := send(http.MethodGet, "/members?q="+q, "", "")
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var members []models.Member
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &members))
+			names := []string{}
+			for _, m := range members {
+				names = append(names, m.Name)
+			}
+			return names
+		}
+
+		assert.Equal(t, []string{"Charles Babbage"}, search("C-0002"))
+		assert.Equal(t, []string{"Grace Hopper"}, search("hop"))
+		assert.Equal(t, []string{"Ada Lovelace", "Grace Hopper"}, search("CE"))
+		assert.Empty(t, search("C-00"))
+	})
+
+	t.Run("List Members - Filter by status", func(t *testing.T) {
+		suspended := newMember("C-0004", "Alan Turing")
+		suspended.Status = models.MemberSuspended
+		require.NoError(t, memberRepo.Create(context.Background(), suspended))
+
+		rec := send(http.MethodGet, "/members?status=suspended", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("List Members - Expiry with offset", func(t *testing.T) {
+		// An hour ago in +05:00, which sorts after now as text in UTC.
+		expired := time.Now().Add(-time.Hour).In(time.FixedZone("", 5*60*60)).Format(time.RFC3339)
+		body := `{"card_number": "C-0008", "name": "Margaret Hamilton", "expires_at": "` + expired + `"}`
+		rec := send(http.MethodPost, "/members", echo.MIMEApplicationJSON, body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		now := url.QueryEscape(time.Now().UTC().Format(time.RFC3339))
+		rec = send(http.MethodGet, "/members?expires_before="+now, "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+
+		rec = send(http.MethodGet, "/members?q=Hamilton&expires_after="+now, "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Update Member - Success", func(t *testing.T) {
+		member := newMember("C-0005", "Edsger Dijkstra")
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+		url := "/members/" + strconv.Itoa(int(member.ID))
+
+		replacement := newMember("C-0005", "Edsger W. Dijkstra")
+		replacement.Phone = "+31 20 000 0000"
+		rec := sendJSON(http.MethodPut, url, replacement)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		updated, err := memberRepo.Read(context.Background(), member.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Edsger W. Dijkstra", updated.Name)
+		assert.Equal(t, "+31 20 000 0000", updated.Phone)
+		assert.Equal(t, member.CreatedAt.Unix(), updated.CreatedAt.Unix())
+	})
+
+	t.Run("Patch Member - Suspend", func(t *testing.T) {
+		member := newMember("C-0006", "Barbara Liskov")
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+		url := "/members/" + strconv.Itoa(int(member.ID))
+
+		rec := send(http.MethodPatch, url, handlers.MIMEMergePatch, `{"status": "suspended"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Member
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, models.MemberSuspended, resp.Status)
+		assert.Equal(t, "Barbara Liskov", resp.Name)
+
+		rec = send(http.MethodPatch, url, handlers.MIMEMergePatch, `{"type": "robot"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("Delete Member - Stale version", func(t *testing.T) {
+		member := newMember("C-0007", "Ken Thompson")
+		require.NoError(t, memberRepo.Create(context.Background(), member))
+		url := "/members/" + strconv.Itoa(int(member.ID))
+
+		req := httptest.NewRequest(http.MethodDelete, url, bytes.NewReader(nil))
+		req.Header.Set("If-Match", `"2"`)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = send(http.MethodDelete, url, "", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = send(http.MethodGet, url, "", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		// The card number is free again once its member is deleted.
+		rec = sendJSON(http.MethodPost, "/members", newMember("C-0007", "Dennis Ritchie"))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The handlers only depend on the store interfaces, so they can be
+// tested without a database.
+func TestBookHandlerMemoryStore(t *testing.T) {
+	db := repository.NewMemoryDB()
+	depth := config.Default().API.MaxIncludeDepth
+	bookHandler := handlers.NewBookHandler(repository.NewMemoryBookStore(db), depth)
+	authorHandler := handlers.NewAuthorHandler(repository.NewMemoryAuthorStore(db), depth)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.GET("/books", bookHandler.ListBooks)
+	e.GET("/books/:id", bookHandler.GetBook)
+	e.POST("/books", bookHandler.CreateBook)
+	e.PATCH("/books/:id", bookHandler.PatchBook)
+	e.GET("/authors/:id", authorHandler.GetAuthor)
+
+	send := func(method, url, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/books", echo.MIMEApplicationJSON, `{"title": "b1", "pages": 100, "authors": [{"name": "a1"}]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var book models.Book
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &book))
+	url := "/books/" + strconv.Itoa(int(book.ID))
+
+	t.Run("Memory Store - Get Book", func(t *testing.T) {
+		rec := send(http.MethodGet, url+"?include=authors.books", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "b1", resp.Title)
+		require.Len(t, resp.Authors, 1)
+		assert.Equal(t, "a1", resp.Authors[0].Name)
+		require.Len(t, resp.Authors[0].Books, 1)
+	})
+
+	t.Run("Memory Store - Configured include depth", func(t *testing.T) {
+		shallow := echo.New()
+		shallow.HTTPErrorHandler = handlers.ErrorHandler
+		shallow.GET("/books/:id", handlers.NewBookHandler(repository.NewMemoryBookStore(db), 1).GetBook)
+
+		req := httptest.NewRequest(http.MethodGet, url+"?include=authors.books", nil)
+		rec := httptest.NewRecorder()
+		shallow.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CodeInvalidQuery)
+
+		req = httptest.NewRequest(http.MethodGet, url+"?include=authors", nil)
+		rec = httptest.NewRecorder()
+		shallow.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Memory Store - Patch Book", func(t *testing.T) {
+		rec := send(http.MethodPatch, url, handlers.MIMEMergePatch, `{"pages": 200}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.Book
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 200, resp.Pages)
+		assert.Equal(t, uint(2), resp.Version)
+	})
+
+	t.Run("Memory Store - Missing author reference", func(t *testing.T) {
+		rec := send(http.MethodPost, "/books", echo.MIMEApplicationJSON, `{"title": "b2", "authors": [{"ID": 999}]}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assertProblem(t, rec, problem.CodeValidationFailed)
+	})
+
+	t.Run("Memory Store - List Books", func(t *testing.T) {
+		rec := send(http.MethodGet, "/books?title~=B", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Memory Store - Not found", func(t *testing.T) {
+		rec := send(http.MethodGet, "/authors/999", "", "")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeAuthorNotFound)
+	})
+}
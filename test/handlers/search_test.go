@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchHandler(t *testing.T) {
+	e, db := setupBookHandler(t)
+	defer testutils.FreeTestDB(t, db)
+
+	bookRepo := repository.NewBookRepository(db)
+	books := []*models.Book{
+		{
+			Title:   "The Go Programming Language",
+			Pages:   380,
+			Authors: []*models.Author{{Name: "Alan Donovan"}},
+		},
+		{
+			Title: "Programming Pearls",
+			Pages: 256,
+		},
+		{
+			Title:   "Dune",
+			Pages:   600,
+			Authors: []*models.Author{{Name: "Frank Herbert"}},
+		},
+	}
+
+	for _, book := range books {
+		require.NoError(t, bookRepo.Create(context.Background(), book))
+	}
+
+	search := func(t *testing.T, url string) ([]models.SearchResult, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response []models.SearchResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response, rec
+	}
+
+	t.Run("Search - Books", func(t *testing.T) {
+		response, rec := search(t, "/search?q=programming")
+
+		require.Len(t, response, 2)
+		assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+		for _, result := range response {
+			assert.Equal(t, models.SearchTypeBook, result.Type)
+			assert.Positive(t, result.Score)
+			assert.Contains(t, result.Snippet, "<mark>Programming</mark>")
+		}
+	})
+
+	t.Run("Search - Authors", func(t *testing.T) {
+		response, _ := search(t, "/search?q=herbert")
+
+		require.Len(t, response, 1)
+		assert.Equal(t, models.SearchTypeAuthor, response[0].Type)
+		assert.Equal(t, "Frank Herbert", response[0].Title)
+	})
+
+	t.Run("Search - Type filter", func(t *testing.T) {
+		response, _ := search(t, "/search?q=dune+or+herbert&type=author")
+
+		require.Len(t, response, 1)
+		assert.Equal(t, models.SearchTypeAuthor, response[0].Type)
+	})
+
+	t.Run("Search - Repeated type", func(t *testing.T) {
+		response, rec := search(t, "/search?q=dune+or+herbert&type=book,book,author")
+
+		require.Len(t, response, 2)
+		assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Search - Phrase and exclusion", func(t *testing.T) {
+		response, _ := search(t, `/search?q="programming+pearls"`)
+		require.Len(t, response, 1)
+		assert.Equal(t, "Programming Pearls", response[0].Title)
+
+		response, _ = search(t, "/search?q=programming+-pearls")
+		require.Len(t, response, 1)
+		assert.Equal(t, "The Go Programming Language", response[0].Title)
+	})
+
+	t.Run("Search - Paginated", func(t *testing.T) {
+		response, rec := search(t, "/search?q=programming&limit=1&page=2")
+
+		require.Len(t, response, 1)
+		assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+		assert.Contains(t, rec.Header().Get("Link"), `rel="prev"`)
+	})
+
+	t.Run("Search - Missing query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
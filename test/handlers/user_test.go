@@ -0,0 +1,244 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/migrations"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/problem"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRBAC returns a sender that authorizes with a bearer token or,
+// if given, a raw Authorization header, and a login that creates a
+// user with roles and returns its access token.
+func setupRBAC(t *testing.T) (send func(method, url, token string, body any, authorization ...string) *httptest.ResponseRecorder, login func(username string, roles ...auth.Role) (*models.User, string)) {
+	e := echo.New()
+	db := testutils.SetupTestDB(t)
+	t.Cleanup(func() { testutils.FreeTestDB(t, db) })
+	require.NoError(t, migrations.Up(db))
+
+	tokens, err := auth.NewTokens(config.Default().Auth)
+	require.NoError(t, err)
+	handlers.SetupRoutes(e, db, tokens, time.Hour, config.Default().Circulation, config.Default().API)
+
+	send = func(method, url, token string, body any, authorization ...string) *httptest.ResponseRecorder {
+		var raw []byte
+		if body != nil {
+			raw, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewReader(raw))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		if len(authorization) > 0 {
+			req.Header.Set(echo.HeaderAuthorization, authorization[0])
+		}
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	login = func(username string, roles ...auth.Role) (*models.User, string) {
+		user := testutils.CreateUser(t, db, username, username+"-password", roles...)
+		return user, testutils.Login(t, e, username, username+"-password").AccessToken
+	}
+
+	return send, login
+}
+
+func TestRolePermissions(t *testing.T) {
+	send, login := setupRBAC(t)
+
+	_, admin := login("admin", auth.RoleAdmin)
+	_, cataloguer := login("cataloguer", auth.RoleCataloguer)
+	_, librarian := login("librarian", auth.RoleLibrarian)
+	_, reader := login("reader", auth.RoleReader)
+	_, nobody := login("nobody")
+
+	rec := send(http.MethodPost, "/books", admin, models.Book{Title: "Dune", Pages: 412})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   any
+		status map[string]int
+	}{
+		{"List books", http.MethodGet, "/books", nil, map[string]int{
+			admin: http.StatusOK, cataloguer: http.StatusOK, librarian: http.StatusOK, reader: http.StatusOK, nobody: http.StatusForbidden,
+		}},
+		{"Search", http.MethodGet, "/search?q=dune", nil, map[string]int{
+			reader: http.StatusOK, nobody: http.StatusForbidden,
+		}},
+		{"Create author", http.MethodPost, "/authors", models.Author{Name: "Frank Herbert"}, map[string]int{
+			reader: http.StatusForbidden, librarian: http.StatusForbidden, cataloguer: http.StatusCreated,
+		}},
+		{"Update book", http.MethodPut, "/books/1", models.Book{Title: "Dune Messiah", Pages: 256}, map[string]int{
+			reader: http.StatusForbidden, cataloguer: http.StatusNoContent,
+		}},
+		{"Create copy", http.MethodPost, "/books/1/copies", newCopy("0000000001"), map[string]int{
+			reader: http.StatusForbidden, librarian: http.StatusCreated,
+		}},
+		{"Delete copy", http.MethodDelete, "/books/1/copies/1", nil, map[string]int{
+			librarian: http.StatusForbidden, cataloguer: http.StatusForbidden, admin: http.StatusNoContent,
+		}},
+		{"Book holds", http.MethodGet, "/books/1/holds", nil, map[string]int{
+			reader: http.StatusForbidden, cataloguer: http.StatusForbidden, librarian: http.StatusOK,
+		}},
+		{"Delete book", http.MethodDelete, "/books/1", nil, map[string]int{
+			reader: http.StatusForbidden, librarian: http.StatusForbidden, cataloguer: http.StatusForbidden, admin: http.StatusNoContent,
+		}},
+		{"Create member", http.MethodPost, "/members", newMember("C-0001", "Ada Lovelace"), map[string]int{
+			reader: http.StatusForbidden, cataloguer: http.StatusForbidden, librarian: http.StatusCreated,
+		}},
+		{"Member loans", http.MethodGet, "/members/1/loans", nil, map[string]int{
+			reader: http.StatusForbidden, cataloguer: http.StatusForbidden, librarian: http.StatusOK,
+		}},
+		{"Delete member", http.MethodDelete, "/members/1", nil, map[string]int{
+			librarian: http.StatusForbidden, admin: http.StatusNoContent,
+		}},
+		{"List users", http.MethodGet, "/users", nil, map[string]int{
+			cataloguer: http.StatusForbidden, admin: http.StatusOK,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run("Permissions - "+tt.name, func(t *testing.T) {
+			// Forbidden requests come first, so they run against the
+			// resource before it's changed.
+			for _, want := range []bool{true, false} {
+				for token, status := range tt.status {
+					if (status == http.StatusForbidden) != want {
+						continue
+					}
+
+					rec := send(tt.method, tt.url, token, tt.body)
+					assert.Equal(t, status, rec.Code, rec.Body.String())
+					if status == http.StatusForbidden {
+						p := assertProblem(t, rec, problem.CodeForbidden)
+						assert.Contains(t, p.Detail, "Missing permission")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler(t *testing.T) {
+	send, login := setupRBAC(t)
+
+	adminUser, admin := login("admin", auth.RoleAdmin)
+	readerUser, reader := login("reader", auth.RoleReader)
+
+	t.Run("Get User - Success", func(t *testing.T) {
+		rec := send(http.MethodGet, fmt.Sprintf("/users/%d", readerUser.ID), admin, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "reader", resp["username"])
+		assert.Equal(t, []any{"reader"}, resp["roles"])
+		assert.NotContains(t, resp, "PasswordHash")
+	})
+
+	t.Run("Get User - Not found", func(t *testing.T) {
+		rec := send(http.MethodGet, "/users/999", admin, nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeUserNotFound)
+	})
+
+	t.Run("List Users - Filter by role", func(t *testing.T) {
+		rec := send(http.MethodGet, "/users?role=admin", admin, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var users []models.User
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &users))
+		require.Len(t, users, 1)
+		assert.Equal(t, "admin", users[0].Username)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Set Roles - Applies immediately", func(t *testing.T) {
+		rec := send(http.MethodPost, "/authors", reader, models.Author{Name: "Ursula K. Le Guin"})
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = send(http.MethodPut, fmt.Sprintf("/users/%d/roles", readerUser.ID), admin,
+			models.RoleAssignment{Roles: []string{"reader", "cataloguer"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var user models.User
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+		assert.ElementsMatch(t, []models.UserRole{{Role: "reader"}, {Role: "cataloguer"}}, user.Roles)
+
+		rec = send(http.MethodPost, "/authors", reader, models.Author{Name: "Ursula K. Le Guin"})
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("Set Roles - Unknown role", func(t *testing.T) {
+		rec := send(http.MethodPut, fmt.Sprintf("/users/%d/roles", readerUser.ID), admin,
+			models.RoleAssignment{Roles: []string{"reader", "superuser"}})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		p := assertProblem(t, rec, problem.CodeValidationFailed)
+		require.Len(t, p.Errors, 1)
+		assert.Equal(t, "roles[1]", p.Errors[0].Field)
+		assert.Equal(t, "must be one of reader, librarian, cataloguer, admin", p.Errors[0].Message)
+	})
+
+	t.Run("Set Roles - Duplicates", func(t *testing.T) {
+		rec := send(http.MethodPut, fmt.Sprintf("/users/%d/roles", readerUser.ID), admin,
+			models.RoleAssignment{Roles: []string{"reader", "reader"}})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assertProblem(t, rec, problem.CodeValidationFailed)
+	})
+
+	t.Run("Set Roles - Last admin", func(t *testing.T) {
+		rec := send(http.MethodPut, fmt.Sprintf("/users/%d/roles", adminUser.ID), admin,
+			models.RoleAssignment{Roles: []string{"reader"}})
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertProblem(t, rec, problem.CodeLastAdmin)
+
+		rec = send(http.MethodGet, "/users", admin, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Set Roles - Admin hands over", func(t *testing.T) {
+		rec := send(http.MethodPut, fmt.Sprintf("/users/%d/roles", readerUser.ID), admin,
+			models.RoleAssignment{Roles: []string{"admin"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = send(http.MethodPut, fmt.Sprintf("/users/%d/roles", adminUser.ID), admin,
+			models.RoleAssignment{Roles: []string{}})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = send(http.MethodGet, "/users", admin, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = send(http.MethodGet, "/users", reader, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Set Roles - User not found", func(t *testing.T) {
+		rec := send(http.MethodPut, "/users/999/roles", reader, models.RoleAssignment{Roles: []string{"reader"}})
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CodeUserNotFound)
+	})
+}
@@ -0,0 +1,42 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	t.Run("Checker - Timeout", func(t *testing.T) {
+		checker := health.NewChecker(50 * time.Millisecond)
+		checker.Add("slow", func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		start := time.Now()
+		report := checker.Run(context.Background())
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, health.StatusFail, report.Status)
+		assert.Contains(t, report.Checks["slow"].Error, "timed out")
+	})
+
+	t.Run("Checker - Schema version", func(t *testing.T) {
+		checker := health.NewChecker(time.Second)
+		checker.Add("current", health.SchemaVersion(func(context.Context) (uint, error) { return 2, nil }, 2))
+		checker.Add("behind", health.SchemaVersion(func(context.Context) (uint, error) { return 1, nil }, 2))
+		checker.Add("failing", health.SchemaVersion(func(context.Context) (uint, error) { return 0, errors.New("no table") }, 2))
+
+		report := checker.Run(context.Background())
+
+		assert.Equal(t, health.StatusFail, report.Status)
+		assert.Equal(t, health.StatusOK, report.Checks["current"].Status)
+		assert.Equal(t, "schema version is 1, expected 2", report.Checks["behind"].Error)
+		assert.Equal(t, "no table", report.Checks["failing"].Error)
+	})
+}
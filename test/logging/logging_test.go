@@ -0,0 +1,202 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/logging"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLogging(sampleRate float64) (*echo.Echo, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logging.New(config.Log{Level: "debug", Format: "json"}, &buf)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Use(logging.RequestIDMiddleware(), logging.AccessLogMiddleware(logger, sampleRate))
+	e.GET("/books/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return problem.New(http.StatusNotFound, problem.CodeBookNotFound, "")
+		}
+		logger.InfoContext(c.Request().Context(), "reading book")
+		return c.NoContent(http.StatusOK)
+	})
+	return e, &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	t.Run("Request ID - Propagated", func(t *testing.T) {
+		e, buf := setupLogging(1)
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		req.Header.Set(echo.HeaderXRequestID, "abc-123")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, "abc-123", rec.Header().Get(echo.HeaderXRequestID))
+		lines := logLines(t, buf)
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			assert.Equal(t, "abc-123", line["request_id"])
+		}
+	})
+
+	t.Run("Request ID - Generated", func(t *testing.T) {
+		e, buf := setupLogging(1)
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		assert.NotEmpty(t, id)
+		for _, line := range logLines(t, buf) {
+			assert.Equal(t, id, line["request_id"])
+		}
+	})
+
+	t.Run("Request ID - Invalid header replaced", func(t *testing.T) {
+		e, _ := setupLogging(1)
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		req.Header.Set(echo.HeaderXRequestID, "bad id\n"+strings.Repeat("x", 200))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		assert.NotEmpty(t, id)
+		assert.NotContains(t, id, "bad id")
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("Access Log - Fields", func(t *testing.T) {
+		e, buf := setupLogging(1)
+		req := httptest.NewRequest(http.MethodGet, "/books/1?token=s3cret&page=2", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logLines(t, buf)
+		require.NotEmpty(t, lines)
+		line := lines[len(lines)-1]
+		assert.Equal(t, "request", line["msg"])
+		assert.Equal(t, "INFO", line["level"])
+		assert.Equal(t, "GET", line["method"])
+		assert.Equal(t, "/books/:id", line["route"])
+		assert.Equal(t, "/books/1", line["path"])
+		assert.Equal(t, float64(http.StatusOK), line["status"])
+		assert.Contains(t, line, "latency_ms")
+		assert.NotContains(t, buf.String(), "s3cret")
+		assert.Contains(t, line["query"], "page=2")
+	})
+
+	t.Run("Access Log - Client error", func(t *testing.T) {
+		e, buf := setupLogging(1)
+		req := httptest.NewRequest(http.MethodGet, "/books/0", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logLines(t, buf)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "WARN", lines[0]["level"])
+		assert.Equal(t, float64(http.StatusNotFound), lines[0]["status"])
+	})
+
+	t.Run("Access Log - Sampling keeps errors", func(t *testing.T) {
+		e, buf := setupLogging(0)
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/1", nil))
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/0", nil))
+
+		var requests []map[string]any
+		for _, line := range logLines(t, buf) {
+			if line["msg"] == "request" {
+				requests = append(requests, line)
+			}
+		}
+		require.Len(t, requests, 1)
+		assert.Equal(t, float64(http.StatusNotFound), requests[0]["status"])
+	})
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(config.Log{Level: "info", Format: "json"}, &buf)
+
+	logger.Info("login", "user", "alice", "password", "s3cret", slog.String("Authorization", "Bearer s3cret"))
+
+	assert.NotContains(t, buf.String(), "s3cret")
+	assert.Contains(t, buf.String(), `"password":"***"`)
+	assert.Contains(t, buf.String(), `"user":"alice"`)
+}
+
+func TestGormLogger(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "abc-123")
+	sql := func() (string, int64) { return "SELECT * FROM books WHERE id = $1", 1 }
+
+	t.Run("Gorm Logger - Statement", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.NewGormLogger(logging.New(config.Log{Level: "info", Format: "json"}, &buf), "info", time.Second, false)
+		logger.Trace(ctx, time.Now(), sql, nil)
+
+		lines := logLines(t, &buf)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "abc-123", lines[0]["request_id"])
+		assert.Equal(t, "SELECT * FROM books WHERE id = $1", lines[0]["sql"])
+		assert.Equal(t, float64(1), lines[0]["rows"])
+	})
+
+	t.Run("Gorm Logger - Slow statement", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.NewGormLogger(logging.New(config.Log{Level: "info", Format: "json"}, &buf), "warn", time.Millisecond, false)
+		logger.Trace(ctx, time.Now(), sql, nil)
+		logger.Trace(ctx, time.Now().Add(-time.Second), sql, nil)
+
+		lines := logLines(t, &buf)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "WARN", lines[0]["level"])
+		assert.Equal(t, "slow sql", lines[0]["msg"])
+	})
+
+	t.Run("Gorm Logger - Error", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.NewGormLogger(logging.New(config.Log{Level: "info", Format: "json"}, &buf), "error", time.Second, false)
+		logger.Trace(ctx, time.Now(), sql, errors.New("connection reset"))
+
+		lines := logLines(t, &buf)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, "connection reset", lines[0]["error"])
+	})
+
+	t.Run("Gorm Logger - Params hidden", func(t *testing.T) {
+		logger := logging.NewGormLogger(slog.Default(), "info", time.Second, false)
+		_, params := logger.ParamsFilter(ctx, "SELECT 1", "s3cret")
+		assert.Empty(t, params)
+
+		logger = logging.NewGormLogger(slog.Default(), "info", time.Second, true)
+		_, params = logger.ParamsFilter(ctx, "SELECT 1", "s3cret")
+		assert.Equal(t, []any{"s3cret"}, params)
+	})
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/metrics"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	m := metrics.New()
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Use(m.Middleware())
+	e.GET("/books/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return problem.New(http.StatusNotFound, problem.CodeBookNotFound, "")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	handlers.SetupMetricsRoutes(e, m.Handler())
+
+	for _, url := range []string{"/books/1", "/books/2", "/books/0", "/no/such/path"} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	text := string(body)
+
+	t.Run("Metrics - Route template", func(t *testing.T) {
+		assert.Contains(t, text, `library_http_requests_total{method="GET",route="/books/:id",status="200"} 2`)
+		assert.NotContains(t, text, `route="/books/1"`)
+	})
+
+	t.Run("Metrics - Error status", func(t *testing.T) {
+		assert.Contains(t, text, `library_http_requests_total{method="GET",route="/books/:id",status="404"} 1`)
+	})
+
+	t.Run("Metrics - Unmatched route", func(t *testing.T) {
+		assert.Contains(t, text, `library_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+		assert.NotContains(t, text, "/no/such/path")
+	})
+
+	t.Run("Metrics - Latency histogram", func(t *testing.T) {
+		assert.Contains(t, text, `library_http_request_duration_seconds_count{method="GET",route="/books/:id",status="200"} 2`)
+	})
+}
@@ -0,0 +1,157 @@
+package migrations_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/4otis/library_api_2025/internal/migrations"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Load Migrations - Ordered by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0010_later.up.sql":   {Data: []byte("create table b ();")},
+			"sql/0010_later.down.sql": {Data: []byte("drop table b;")},
+			"sql/0002_first.up.sql":   {Data: []byte("create table a ();")},
+			"sql/0002_first.down.sql": {Data: []byte("drop table a;")},
+		}
+
+		loaded, err := migrations.Load(fsys, "sql")
+		require.NoError(t, err)
+		require.Len(t, loaded, 2)
+
+		assert.Equal(t, uint(2), loaded[0].Version)
+		assert.Equal(t, "first", loaded[0].Name)
+		assert.Equal(t, "create table a ();", loaded[0].Up)
+		assert.Equal(t, "drop table a;", loaded[0].Down)
+		assert.NotEmpty(t, loaded[0].Checksum)
+		assert.Equal(t, uint(10), loaded[1].Version)
+		assert.NotEqual(t, loaded[0].Checksum, loaded[1].Checksum)
+	})
+
+	t.Run("Load Migrations - Missing down script", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0001_init.up.sql": {Data: []byte("create table a ();")},
+		}
+
+		_, err := migrations.Load(fsys, "sql")
+		assert.Error(t, err)
+	})
+
+	t.Run("Load Migrations - Unexpected file", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/init.sql": {Data: []byte("create table a ();")},
+		}
+
+		_, err := migrations.Load(fsys, "sql")
+		assert.Error(t, err)
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.FreeTestDB(t, db)
+
+	m, err := migrations.NewMigrator(db)
+	require.NoError(t, err)
+
+	t.Run("Migrate - Up", func(t *testing.T) {
+		done, err := m.Up()
+		require.NoError(t, err)
+		assert.NotEmpty(t, done)
+
+		version, err := m.Version(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, m.Latest(), version)
+		assert.True(t, db.Migrator().HasTable("books"))
+	})
+
+	t.Run("Migrate - Up is idempotent", func(t *testing.T) {
+		require.NoError(t, db.Exec("insert into books (title, pages) values ('b1', 100)").Error)
+
+		done, err := m.Up()
+		require.NoError(t, err)
+		assert.Empty(t, done)
+
+		var cnt int64
+		db.Table("books").Count(&cnt)
+		assert.Equal(t, int64(1), cnt)
+	})
+
+	t.Run("Migrate - Status", func(t *testing.T) {
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		require.NotEmpty(t, statuses)
+
+		for _, s := range statuses {
+			assert.Equal(t, migrations.StateApplied, s.State)
+			assert.NotNil(t, s.AppliedAt)
+		}
+	})
+
+	t.Run("Migrate - Modified migration", func(t *testing.T) {
+		require.NoError(t, db.Exec("update schema_migrations set checksum = 'edited' where version = 1").Error)
+
+		_, err := m.Up()
+		assert.ErrorIs(t, err, migrations.ErrChecksumMismatch)
+
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		assert.Equal(t, migrations.StateModified, statuses[0].State)
+	})
+
+	t.Run("Migrate - Down", func(t *testing.T) {
+		statuses, err := m.Status()
+		require.NoError(t, err)
+
+		done, err := m.Down(len(statuses))
+		require.NoError(t, err)
+		assert.Len(t, done, len(statuses))
+
+		version, err := m.Version(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, version)
+		assert.False(t, db.Migrator().HasTable("books"))
+	})
+}
+
+func TestMigratorLegacySchema(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.FreeTestDB(t, db)
+	if db.Dialector.Name() != "postgres" {
+		t.Skip("Only Postgres databases predate versioned migrations")
+	}
+
+	m, err := migrations.NewMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	// Tables adopted without the columns added since the server
+	// created them itself.
+	require.NoError(t, db.Exec(`
+		alter table books drop column version, drop column search_vector;
+		alter table authors drop column version, drop column search_vector;
+		delete from schema_migrations where name = 'adopt_legacy_schema';
+		insert into books (title, pages) values ('Dune', 412);`).Error)
+
+	t.Run("Migrate - Adopts legacy schema", func(t *testing.T) {
+		done, err := m.Up()
+		require.NoError(t, err)
+		require.Len(t, done, 1)
+
+		for _, table := range []string{"books", "authors"} {
+			assert.True(t, db.Migrator().HasColumn(table, "version"), table)
+			assert.True(t, db.Migrator().HasColumn(table, "search_vector"), table)
+			assert.True(t, db.Migrator().HasIndex(table, table+"_search_vector_idx"), table)
+		}
+
+		var version uint
+		require.NoError(t, db.Raw("select version from books where title = 'Dune'").Scan(&version).Error)
+		assert.Equal(t, uint(1), version)
+	})
+}
@@ -0,0 +1,561 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/migrations"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/repository"
+	testutils "github.com/4otis/library_api_2025/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// storeFactory returns empty stores for each conformance case.
+type storeFactory func(t *testing.T) (repository.BookStore, repository.AuthorStore)
+
+func TestMemoryStores(t *testing.T) {
+	testStores(t, func(t *testing.T) (repository.BookStore, repository.AuthorStore) {
+		db := repository.NewMemoryDB()
+		return repository.NewMemoryBookStore(db), repository.NewMemoryAuthorStore(db)
+	})
+}
+
+func TestSQLStores(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.FreeTestDB(t, db)
+
+	err := migrations.Up(db)
+	if err != nil {
+		t.Fatal("Error. Failed to run migrations.")
+	}
+
+	testStores(t, func(t *testing.T) (repository.BookStore, repository.AuthorStore) {
+		db.Exec("delete from books_authors")
+		db.Exec("delete from books")
+		db.Exec("delete from authors")
+		return repository.NewBookRepository(db), repository.NewAuthorRepository(db)
+	})
+}
+
+func TestMemoryStoresConcurrency(t *testing.T) {
+	db := repository.NewMemoryDB()
+	books, authors := repository.NewMemoryBookStore(db), repository.NewMemoryAuthorStore(db)
+	ctx := context.Background()
+
+	book := &models.Book{Title: "b", Authors: []*models.Author{{Name: "a"}}}
+	require.NoError(t, books.Create(ctx, book))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, books.Create(ctx, &models.Book{Title: fmt.Sprint("b", i), Authors: []*models.Author{{Name: "a"}}}))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := books.Patch(ctx, book.ID, 0, func(b *models.Book) error {
+				// The patch runs outside the lock, so it may read the store.
+				_, err := books.MissingAuthors(ctx, []uint{1})
+				b.Pages++
+				return err
+			})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := authors.ReadAll(ctx, repository.ListQuery{Limit: repository.MaxLimit, Preloads: []string{"Books"}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	stored, err := books.Read(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, stored.Pages)
+	assert.Equal(t, uint(21), stored.Version)
+
+	_, page, err := books.ReadAll(ctx, repository.ListQuery{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), page.Total)
+}
+
+// testStores is the conformance suite every BookStore and AuthorStore
+// implementation has to pass.
+func testStores(t *testing.T, newStores storeFactory) {
+	ctx := context.Background()
+
+	t.Run("Create Book - Success", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1", Pages: 100, Authors: []*models.Author{{Name: "a1"}}}
+		require.NoError(t, books.Create(ctx, book))
+		assert.NotZero(t, book.ID)
+		assert.Equal(t, uint(1), book.Version)
+		assert.False(t, book.CreatedAt.IsZero())
+		assert.NotZero(t, book.Authors[0].ID)
+
+		stored, err := books.Read(ctx, book.ID, "Authors")
+		require.NoError(t, err)
+		assert.Equal(t, "b1", stored.Title)
+		assert.Equal(t, 100, stored.Pages)
+		require.Len(t, stored.Authors, 1)
+		assert.Equal(t, "a1", stored.Authors[0].Name)
+	})
+
+	t.Run("Create Book - Existing author", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		author := &models.Author{Name: "a1"}
+		require.NoError(t, authors.Create(ctx, author))
+
+		book := &models.Book{Title: "b1", Authors: []*models.Author{{Model: gorm.Model{ID: author.ID}}}}
+		require.NoError(t, books.Create(ctx, book))
+
+		stored, err := authors.Read(ctx, author.ID, "Books")
+		require.NoError(t, err)
+		assert.Equal(t, "a1", stored.Name)
+		assert.Equal(t, uint(1), stored.Version)
+		require.Len(t, stored.Books, 1)
+		assert.Equal(t, book.ID, stored.Books[0].ID)
+	})
+
+	t.Run("Read Book - Not found", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		_, err := books.Read(ctx, 999)
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	})
+
+	t.Run("Read Book - Preloads", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		a1 := &models.Author{Name: "a1"}
+		b1 := &models.Book{Title: "b1", Authors: []*models.Author{a1}}
+		b2 := &models.Book{Title: "b2", Authors: []*models.Author{a1, {Name: "a2"}}}
+		require.NoError(t, books.Create(ctx, b1))
+		require.NoError(t, books.Create(ctx, b2))
+
+		plain, err := books.Read(ctx, b2.ID)
+		require.NoError(t, err)
+		assert.Empty(t, plain.Authors)
+
+		nested, err := books.Read(ctx, b2.ID, "Authors.Books")
+		require.NoError(t, err)
+		require.Len(t, nested.Authors, 2)
+		assert.ElementsMatch(t, []string{"a1", "a2"}, []string{nested.Authors[0].Name, nested.Authors[1].Name})
+		for _, author := range nested.Authors {
+			if author.Name == "a1" {
+				assert.Len(t, author.Books, 2)
+			} else {
+				assert.Len(t, author.Books, 1)
+			}
+		}
+	})
+
+	t.Run("Update Book - Success", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		a1, a2 := &models.Author{Name: "a1"}, &models.Author{Name: "a2"}
+		book := &models.Book{Title: "b1", Pages: 100, Authors: []*models.Author{a1, a2}}
+		require.NoError(t, books.Create(ctx, book))
+
+		newBook := &models.Book{Title: "b2", Authors: []*models.Author{{Model: gorm.Model{ID: a1.ID}}, {Name: "a3"}}}
+		require.NoError(t, books.Update(ctx, book.ID, book.Version, newBook))
+		assert.Equal(t, book.ID, newBook.ID)
+		assert.Equal(t, uint(2), newBook.Version)
+
+		stored, err := books.Read(ctx, book.ID, "Authors")
+		require.NoError(t, err)
+		assert.Equal(t, "b2", stored.Title)
+		assert.Zero(t, stored.Pages)
+		assert.Equal(t, uint(2), stored.Version)
+		require.Len(t, stored.Authors, 2)
+		assert.ElementsMatch(t, []string{"a1", "a3"}, []string{stored.Authors[0].Name, stored.Authors[1].Name})
+
+		detached, err := authors.Read(ctx, a2.ID, "Books")
+		require.NoError(t, err)
+		assert.Empty(t, detached.Books)
+	})
+
+	t.Run("Update Book - Detach all authors", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1", Authors: []*models.Author{{Name: "a1"}}}
+		require.NoError(t, books.Create(ctx, book))
+		require.NoError(t, books.Update(ctx, book.ID, 0, &models.Book{Title: "b1"}))
+
+		stored, err := books.Read(ctx, book.ID, "Authors")
+		require.NoError(t, err)
+		assert.Empty(t, stored.Authors)
+	})
+
+	t.Run("Update Book - Version mismatch", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1"}
+		require.NoError(t, books.Create(ctx, book))
+
+		err := books.Update(ctx, book.ID, book.Version+1, &models.Book{Title: "b2"})
+		assert.True(t, errors.Is(err, repository.ErrVersionMismatch))
+
+		err = books.Update(ctx, book.ID+1, 0, &models.Book{Title: "b2"})
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+		stored, err := books.Read(ctx, book.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b1", stored.Title)
+	})
+
+	t.Run("Patch Book - Success", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1", Pages: 100, Authors: []*models.Author{{Name: "a1"}}}
+		require.NoError(t, books.Create(ctx, book))
+
+		patched, err := books.Patch(ctx, book.ID, book.Version, func(b *models.Book) error {
+			require.Len(t, b.Authors, 1)
+			b.Pages = 200
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "b1", patched.Title)
+		assert.Equal(t, 200, patched.Pages)
+		assert.Equal(t, uint(2), patched.Version)
+		require.Len(t, patched.Authors, 1)
+		assert.Equal(t, "a1", patched.Authors[0].Name)
+	})
+
+	t.Run("Patch Book - Callback error", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1"}
+		require.NoError(t, books.Create(ctx, book))
+
+		_, err := books.Patch(ctx, book.ID, 0, func(b *models.Book) error {
+			b.Title = "b2"
+			return assert.AnError
+		})
+		assert.Equal(t, assert.AnError, err)
+
+		_, err = books.Patch(ctx, book.ID, book.Version+1, func(*models.Book) error { return nil })
+		assert.True(t, errors.Is(err, repository.ErrVersionMismatch))
+
+		stored, err := books.Read(ctx, book.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b1", stored.Title)
+		assert.Equal(t, book.Version, stored.Version)
+	})
+
+	t.Run("Delete Book - Success", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		author := &models.Author{Name: "a1"}
+		book := &models.Book{Title: "b1", Authors: []*models.Author{author}}
+		require.NoError(t, books.Create(ctx, book))
+		require.NoError(t, books.Delete(ctx, book.ID, book.Version))
+
+		_, err := books.Read(ctx, book.ID)
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+		stored, err := authors.Read(ctx, author.ID, "Books")
+		require.NoError(t, err)
+		assert.Empty(t, stored.Books)
+	})
+
+	t.Run("Delete Book - Missing", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		book := &models.Book{Title: "b1"}
+		require.NoError(t, books.Create(ctx, book))
+
+		assert.True(t, errors.Is(books.Delete(ctx, book.ID, book.Version+1), repository.ErrVersionMismatch))
+		assert.True(t, errors.Is(books.Delete(ctx, book.ID+1, 1), gorm.ErrRecordNotFound))
+		assert.True(t, errors.Is(books.Delete(ctx, book.ID+1, 0), gorm.ErrRecordNotFound))
+
+		_, err := books.Read(ctx, book.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing Authors - Success", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		a1, a2 := &models.Author{Name: "a1"}, &models.Author{Name: "a2"}
+		require.NoError(t, authors.Create(ctx, a1))
+		require.NoError(t, authors.Create(ctx, a2))
+		require.NoError(t, authors.Delete(ctx, a2.ID, 0))
+
+		missing, err := books.MissingAuthors(ctx, []uint{a1.ID, a2.ID, a2.ID + 100})
+		require.NoError(t, err)
+		assert.Equal(t, []uint{a2.ID, a2.ID + 100}, missing)
+
+		missing, err = books.MissingAuthors(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, missing)
+	})
+
+	t.Run("List Books - Filters", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		a1, a2 := &models.Author{Name: "a1"}, &models.Author{Name: "a2"}
+		for _, book := range []*models.Book{
+			{Title: "Dune", Pages: 100, Authors: []*models.Author{a1}},
+			{Title: "dune messiah", Pages: 200, Authors: []*models.Author{a1, a2}},
+			{Title: "Solaris", Pages: 300, Authors: []*models.Author{a2}},
+		} {
+			require.NoError(t, books.Create(ctx, book))
+		}
+
+		for filters, want := range map[string][]string{
+			"title=Dune":                {"Dune"},
+			"title~=DUNE":               {"Dune", "dune messiah"},
+			"title~=_":                  {},
+			"pages_min=200":             {"dune messiah", "Solaris"},
+			"pages_max=200":             {"Dune", "dune messiah"},
+			"author_id=a2":              {"dune messiah", "Solaris"},
+			"created_after=2000-01-01":  {"Dune", "dune messiah", "Solaris"},
+			"created_before=2000-01-01": {},
+		} {
+			key, value, _ := strings.Cut(filters, "=")
+			if value == "a2" {
+				value = fmt.Sprint(a2.ID)
+			}
+
+			list, page, err := books.ReadAll(ctx, repository.ListQuery{Limit: 20, Filters: map[string]string{key: value}})
+			require.NoError(t, err, filters)
+			assert.Equal(t, int64(len(want)), page.Total, filters)
+			assert.Equal(t, want, bookTitles(list), filters)
+		}
+	})
+
+	t.Run("List Books - Invalid query", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		for _, tc := range []struct {
+			q      repository.ListQuery
+			param  string
+			reason string
+		}{
+			{repository.ListQuery{Limit: 20, Filters: map[string]string{"isbn": "1"}}, "isbn", "unknown filter"},
+			{repository.ListQuery{Limit: 20, Filters: map[string]string{"pages_min": "many"}}, "pages_min", "expected an integer"},
+			{repository.ListQuery{Limit: 20, Filters: map[string]string{"author_id": "-1"}}, "author_id", "expected an id"},
+			{repository.ListQuery{Limit: 20, Sort: []string{"title"}}, "sort", "not supported with cursor pagination"},
+			{repository.ListQuery{Limit: 20, Page: 1, Sort: []string{"isbn"}}, "sort", `unknown field "isbn"`},
+		} {
+			_, _, err := books.ReadAll(ctx, tc.q)
+
+			var invalid *repository.InvalidQueryError
+			require.True(t, errors.As(err, &invalid), tc.param)
+			assert.Equal(t, tc.param, invalid.Param)
+			assert.Equal(t, tc.reason, invalid.Reason)
+		}
+	})
+
+	t.Run("List Books - Sort", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		for _, book := range []*models.Book{
+			{Title: "b", Pages: 100},
+			{Title: "a", Pages: 200},
+			{Title: "c", Pages: 100},
+		} {
+			require.NoError(t, books.Create(ctx, book))
+		}
+
+		list, _, err := books.ReadAll(ctx, repository.ListQuery{Limit: 20, Page: 1, Sort: []string{"-pages", "title"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, bookTitles(list))
+
+		list, _, err = books.ReadAll(ctx, repository.ListQuery{Limit: 20, Page: 1, Sort: []string{"pages"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c", "a"}, bookTitles(list))
+	})
+
+	t.Run("List Books - Offset pagination", func(t *testing.T) {
+		books, _ := newStores(t)
+		createBooks(t, books, 5)
+
+		list, page, err := books.ReadAll(ctx, repository.ListQuery{Limit: 2, Page: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b3", "b4"}, bookTitles(list))
+		assert.Equal(t, int64(5), page.Total)
+		assert.Nil(t, page.Next)
+		assert.Nil(t, page.Prev)
+
+		list, _, err = books.ReadAll(ctx, repository.ListQuery{Limit: 2, Page: 4})
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("List Books - Keyset pagination", func(t *testing.T) {
+		books, _ := newStores(t)
+		createBooks(t, books, 5)
+
+		var titles []string
+		q := repository.ListQuery{Limit: 2}
+		var pages []repository.Page
+		for {
+			list, page, err := books.ReadAll(ctx, q)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), page.Total)
+			titles = append(titles, bookTitles(list)...)
+			pages = append(pages, page)
+			if page.Next == nil {
+				break
+			}
+			q.Cursor = page.Next
+		}
+		assert.Equal(t, []string{"b1", "b2", "b3", "b4", "b5"}, titles)
+		require.Len(t, pages, 3)
+		assert.Nil(t, pages[0].Prev)
+
+		list, page, err := books.ReadAll(ctx, repository.ListQuery{Limit: 2, Cursor: pages[2].Prev})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b3", "b4"}, bookTitles(list))
+		require.NotNil(t, page.Next)
+		require.NotNil(t, page.Prev)
+
+		list, page, err = books.ReadAll(ctx, repository.ListQuery{Limit: 2, Cursor: page.Prev})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b1", "b2"}, bookTitles(list))
+		assert.NotNil(t, page.Next)
+		assert.Nil(t, page.Prev)
+	})
+
+	t.Run("List Books - Preloads", func(t *testing.T) {
+		books, _ := newStores(t)
+
+		require.NoError(t, books.Create(ctx, &models.Book{Title: "b1", Authors: []*models.Author{{Name: "a1"}}}))
+		require.NoError(t, books.Create(ctx, &models.Book{Title: "b2"}))
+
+		list, _, err := books.ReadAll(ctx, repository.ListQuery{Limit: 20, Preloads: []string{"Authors"}})
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.Len(t, list[0].Authors, 1)
+		assert.Equal(t, "a1", list[0].Authors[0].Name)
+		assert.Empty(t, list[1].Authors)
+	})
+
+	t.Run("Create Author - With books", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		author := &models.Author{Name: "a1", Books: []*models.Book{{Title: "b1"}, {Title: "b2"}}}
+		require.NoError(t, authors.Create(ctx, author))
+		assert.NotZero(t, author.ID)
+		assert.Equal(t, uint(1), author.Version)
+
+		stored, err := books.Read(ctx, author.Books[1].ID, "Authors")
+		require.NoError(t, err)
+		assert.Equal(t, "b2", stored.Title)
+		require.Len(t, stored.Authors, 1)
+		assert.Equal(t, author.ID, stored.Authors[0].ID)
+	})
+
+	t.Run("Update Author - Replaces books", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		b1 := &models.Book{Title: "b1"}
+		require.NoError(t, books.Create(ctx, b1))
+		author := &models.Author{Name: "a1", Books: []*models.Book{{Title: "b2"}}}
+		require.NoError(t, authors.Create(ctx, author))
+
+		newAuthor := &models.Author{Name: "a2", Books: []*models.Book{{Model: gorm.Model{ID: b1.ID}}}}
+		require.NoError(t, authors.Update(ctx, author.ID, author.Version, newAuthor))
+		assert.Equal(t, uint(2), newAuthor.Version)
+
+		stored, err := authors.Read(ctx, author.ID, "Books")
+		require.NoError(t, err)
+		assert.Equal(t, "a2", stored.Name)
+		require.Len(t, stored.Books, 1)
+		assert.Equal(t, "b1", stored.Books[0].Title)
+
+		err = authors.Update(ctx, author.ID, 1, &models.Author{Name: "a3"})
+		assert.True(t, errors.Is(err, repository.ErrVersionMismatch))
+	})
+
+	t.Run("Patch Author - Success", func(t *testing.T) {
+		_, authors := newStores(t)
+
+		author := &models.Author{Name: "a1", Books: []*models.Book{{Title: "b1"}}}
+		require.NoError(t, authors.Create(ctx, author))
+
+		patched, err := authors.Patch(ctx, author.ID, author.Version, func(a *models.Author) error {
+			a.Name = "a2"
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "a2", patched.Name)
+		assert.Equal(t, uint(2), patched.Version)
+		require.Len(t, patched.Books, 1)
+
+		_, err = authors.Patch(ctx, author.ID+1, 0, func(*models.Author) error { return nil })
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	})
+
+	t.Run("Delete Author - Detaches books", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		author := &models.Author{Name: "a1"}
+		book := &models.Book{Title: "b1", Authors: []*models.Author{author}}
+		require.NoError(t, books.Create(ctx, book))
+		require.NoError(t, authors.Delete(ctx, author.ID, 0))
+		assert.True(t, errors.Is(authors.Delete(ctx, author.ID, 0), gorm.ErrRecordNotFound))
+
+		_, err := authors.Read(ctx, author.ID)
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+		stored, err := books.Read(ctx, book.ID, "Authors")
+		require.NoError(t, err)
+		assert.Empty(t, stored.Authors)
+	})
+
+	t.Run("List Authors - Filters", func(t *testing.T) {
+		books, authors := newStores(t)
+
+		a1, a2 := &models.Author{Name: "Lem"}, &models.Author{Name: "Herbert"}
+		book := &models.Book{Title: "b1", Authors: []*models.Author{a2}}
+		require.NoError(t, authors.Create(ctx, a1))
+		require.NoError(t, books.Create(ctx, book))
+
+		list, page, err := authors.ReadAll(ctx, repository.ListQuery{Limit: 20, Filters: map[string]string{"name~": "e"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), page.Total)
+		assert.Len(t, list, 2)
+
+		list, _, err = authors.ReadAll(ctx, repository.ListQuery{Limit: 20, Filters: map[string]string{"book_id": fmt.Sprint(book.ID)}})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "Herbert", list[0].Name)
+
+		list, _, err = authors.ReadAll(ctx, repository.ListQuery{Limit: 20, Page: 1, Sort: []string{"name"}, Preloads: []string{"Books"}})
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, "Herbert", list[0].Name)
+		assert.Len(t, list[0].Books, 1)
+		assert.Empty(t, list[1].Books)
+	})
+}
+
+func createBooks(t *testing.T, books repository.BookStore, n int) {
+	for i := range n {
+		require.NoError(t, books.Create(context.Background(), &models.Book{Title: fmt.Sprint("b", i+1)}))
+		// Keeps created_at distinct on coarse clocks.
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func bookTitles(books []*models.Book) []string {
+	titles := []string{}
+	for _, book := range books {
+		titles = append(titles, book.Title)
+	}
+	return titles
+}
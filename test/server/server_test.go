@@ -0,0 +1,135 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerShutdown(t *testing.T) {
+	cfg := config.Default()
+	cfg.Shutdown.DrainTimeout = 100 * time.Millisecond
+	cfg.Shutdown.Timeout = 2 * time.Second
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv := server.New(cfg, handler)
+
+	var mu sync.Mutex
+	var stopped []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, name)
+	}
+	for _, name := range []string{"first", "second"} {
+		srv.Go(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			record(name)
+			return ctx.Err()
+		})
+	}
+	srv.OnStop("database", func(context.Context) error {
+		record("database")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, ln)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	assert.False(t, srv.Draining())
+	cancel()
+
+	t.Run("Shutdown - Draining", func(t *testing.T) {
+		assert.Eventually(t, srv.Draining, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Shutdown - In-flight request completes", func(t *testing.T) {
+		res := <-inFlight
+		require.NoError(t, res.err)
+		assert.Equal(t, "done", res.body)
+	})
+
+	t.Run("Shutdown - Stop order", func(t *testing.T) {
+		require.NoError(t, <-served)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"second", "first", "database"}, stopped)
+	})
+
+	t.Run("Shutdown - New connections refused", func(t *testing.T) {
+		_, err := http.Get("http://" + ln.Addr().String())
+		assert.Error(t, err)
+	})
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.Shutdown.DrainTimeout = 0
+	cfg.Shutdown.Timeout = 100 * time.Millisecond
+
+	srv := server.New(cfg, http.NotFoundHandler())
+	srv.Go("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	closeErr := errors.New("close failed")
+	var hooks []string
+	srv.OnStop("database", func(ctx context.Context) error {
+		hooks = append(hooks, "database")
+		return closeErr
+	})
+	srv.OnStop("tracing", func(ctx context.Context) error {
+		hooks = append(hooks, "tracing")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = srv.Serve(ctx, ln)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, closeErr)
+	assert.Equal(t, []string{"database", "tracing"}, hooks)
+}
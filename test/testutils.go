@@ -1,19 +1,45 @@
 package testutils
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
-	"gorm.io/driver/postgres"
+	"github.com/4otis/library_api_2025/internal/auth"
+	"github.com/4otis/library_api_2025/internal/config"
+	"github.com/4otis/library_api_2025/internal/database"
+	"github.com/4otis/library_api_2025/internal/models"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
+// SetupTestDB connects to the database configured like the server,
+// e.g. LIBRARY_DB_DRIVER=sqlite runs the tests against SQLite. Each
+// test gets its own Postgres schema or SQLite file.
 func SetupTestDB(t *testing.T) *gorm.DB {
-	dsn := "host=localhost user=postgres password=password dbname=library port=5432 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Error. Invalid test DB configuration: %v", err)
+	}
+
+	if cfg.DB.Driver == "sqlite" {
+		cfg.DB.Path = filepath.Join(t.TempDir(), "test.db")
+	}
+
+	db, err := database.Open(cfg.DB, &gorm.Config{TranslateError: true})
 	if err != nil {
 		t.Fatalf("Error. Failed to connect to test DB: %v", err)
 	}
 
+	if cfg.DB.Driver == "sqlite" {
+		return db
+	}
+
 	schema := "test_" + t.Name()
 	db.Exec("drop schema if exists " + schema + " cascade")
 	db.Exec("create schema " + schema)
@@ -23,6 +49,63 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 }
 
 func FreeTestDB(t *testing.T, db *gorm.DB) {
+	if db.Dialector.Name() == "sqlite" {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return
+	}
+
 	schema := "test_" + t.Name()
 	db.Exec("drop schema if exists " + schema + " cascade")
 }
+
+// CreateUser stores a user with the given password.
+func CreateUser(t *testing.T, db *gorm.DB, username, password string, roles ...auth.Role) *models.User {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("Error. Failed to hash password: %v", err)
+	}
+
+	user := &models.User{Username: username, PasswordHash: hash}
+	for _, role := range roles {
+		user.Roles = append(user.Roles, models.UserRole{Role: string(role)})
+	}
+	if err := repository.NewUserRepository(db).Create(context.Background(), user); err != nil {
+		t.Fatalf("Error. Failed to create user: %v", err)
+	}
+	return user
+}
+
+// Login logs in through the /auth/login route of e and returns the
+// tokens.
+func Login(t *testing.T, e *echo.Echo, username, password string) models.Tokens {
+	body, _ := json.Marshal(models.Credentials{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	var tokens models.Tokens
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &tokens) != nil {
+		t.Fatalf("Error. Failed to log in: %d %s", rec.Code, rec.Body)
+	}
+	return tokens
+}
+
+// Authorize creates an admin, logs it in and sends its access token
+// with every later request to e that has no Authorization header.
+func Authorize(t *testing.T, e *echo.Echo, db *gorm.DB) {
+	CreateUser(t, db, "tester", "tester-password", auth.RoleAdmin)
+	token := Login(t, e, "tester", "tester-password").AccessToken
+
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(echo.HeaderAuthorization) == "" {
+				c.Request().Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			}
+			return next(c)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/4otis/library_api_2025/internal/handlers"
+	"github.com/4otis/library_api_2025/internal/problem"
+	"github.com/4otis/library_api_2025/internal/repository"
+	"github.com/4otis/library_api_2025/internal/tracing"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID = "00f067aa0ba902b7"
+)
+
+func setupTracing(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func spanNamed(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := setupTracing(t)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Use(tracing.Middleware())
+	e.GET("/books/:id", func(c echo.Context) error {
+		_, span := tracing.Start(c.Request().Context(), "BookHandler.GetBook")
+		defer span.End()
+
+		if c.Param("id") == "0" {
+			return problem.Internal(assert.AnError)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	t.Run("Tracing - Propagated traceparent", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		server := spanNamed(recorder.Ended(), "GET /books/:id")
+		require.NotNil(t, server)
+		assert.Equal(t, traceID, server.SpanContext().TraceID().String())
+		assert.Equal(t, parentID, server.Parent().SpanID().String())
+		assert.Equal(t, "/books/:id", attr(server, "http.route").AsString())
+		assert.Equal(t, int64(http.StatusOK), attr(server, "http.response.status_code").AsInt64())
+		assert.Equal(t, "00-"+traceID+"-"+server.SpanContext().SpanID().String()+"-01", rec.Header().Get("traceparent"))
+
+		handler := spanNamed(recorder.Ended(), "BookHandler.GetBook")
+		require.NotNil(t, handler)
+		assert.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+	})
+
+	t.Run("Tracing - New trace", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.NotEmpty(t, rec.Header().Get("traceparent"))
+		assert.NotContains(t, rec.Header().Get("traceparent"), traceID)
+	})
+
+	t.Run("Tracing - Server error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/0", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		server := spans[len(spans)-1]
+		assert.Equal(t, "GET /books/:id", server.Name())
+		assert.Equal(t, codes.Error, server.Status().Code)
+		assert.Equal(t, int64(http.StatusInternalServerError), attr(server, "http.response.status_code").AsInt64())
+	})
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := setupTracing(t)
+
+	// A dry run builds the statements without a database.
+	db, err := gorm.Open(postgres.Open("postgres://localhost/library"), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(tracing.NewGormPlugin()))
+
+	t.Run("Tracing - Repository and SQL spans", func(t *testing.T) {
+		_, _ = repository.NewBookRepository(db).Read(context.Background(), 42)
+
+		repo := spanNamed(recorder.Ended(), "BookRepository.Read")
+		require.NotNil(t, repo)
+		query := spanNamed(recorder.Ended(), "SELECT books")
+		require.NotNil(t, query)
+		assert.Equal(t, repo.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Equal(t, "postgresql", attr(query, "db.system.name").AsString())
+		assert.Contains(t, attr(query, "db.query.text").AsString(), "$1")
+		assert.NotContains(t, attr(query, "db.query.text").AsString(), "42")
+	})
+
+	t.Run("Tracing - Raw SQL sanitized", func(t *testing.T) {
+		var n int64
+		db.Raw("select count(*) from books where title = 'It''s secret' and pages > 100").Scan(&n)
+
+		spans := recorder.Ended()
+		query := attr(spans[len(spans)-1], "db.query.text").AsString()
+		assert.Equal(t, "select count(*) from books where title = ? and pages > ?", query)
+	})
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	for sql, want := range map[string]string{
+		`SELECT * FROM "books" WHERE "books"."id" = $1 LIMIT $2`: `SELECT * FROM "books" WHERE "books"."id" = $1 LIMIT $2`,
+		`SELECT * FROM books_authors WHERE book_id = 7`:          `SELECT * FROM books_authors WHERE book_id = ?`,
+		`UPDATE users SET password = 'hunter2', score = 1.5`:     `UPDATE users SET password = ?, score = ?`,
+	} {
+		assert.Equal(t, want, tracing.SanitizeSQL(sql))
+	}
+}